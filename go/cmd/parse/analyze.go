@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// analyze prints a structural report for a diagram: strongly connected
+// components, livelocks, elementary cycles, the checkpoints every run from
+// START to END passes through and the shortest path between two states.
+func analyze(args []string) int {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Enable verbose logging output")
+	from := fs.String("from", "START", "Source `state` for the shortest path")
+	to := fs.String("to", "END", "Destination `state` for the shortest path")
+	maxCycles := fs.Int("max-cycles", 100, "Maximum number of cycles to list (0 means no limit)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: parse analyze [flags] diagram\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	g, err := loadGraph(fs.Arg(0), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %v\n", err)
+		return 1
+	}
+
+	w := os.Stdout
+	fmt.Fprintf(w, "states: %d\n", len(g.Nodes))
+
+	section(w, "strongly connected components")
+	var sccs []string
+	for _, scc := range g.StronglyConnectedComponents() {
+		if len(scc) > 1 {
+			sccs = append(sccs, "{"+strings.Join(scc, ", ")+"}")
+		}
+	}
+	items(w, sccs)
+
+	section(w, "livelocks (cycles with no path to END)")
+	var livelocks []string
+	for _, scc := range g.Livelocks("END") {
+		livelocks = append(livelocks, "{"+strings.Join(scc, ", ")+"}")
+	}
+	items(w, livelocks)
+
+	section(w, "cycles")
+	cycles, truncated := g.Cycles(*maxCycles)
+	var lines []string
+	for _, c := range cycles {
+		lines = append(lines, strings.Join(c, " -> ")+" -> "+c[0])
+	}
+	items(w, lines)
+	if truncated {
+		fmt.Fprintf(w, "  ... more than %d cycles, raise -max-cycles to list them\n", *maxCycles)
+	}
+
+	section(w, "checkpoints (states on every path from START to END)")
+	if dom, err := g.Dominators("START"); err != nil {
+		fmt.Fprintf(w, "  %v\n", err)
+	} else if end, ok := dom["END"]; !ok {
+		fmt.Fprintln(w, "  END is not reachable from START")
+	} else {
+		var checkpoints []string
+		for _, node := range end {
+			if node != "START" && node != "END" {
+				checkpoints = append(checkpoints, node)
+			}
+		}
+		items(w, checkpoints)
+	}
+
+	section(w, fmt.Sprintf("shortest path from %s to %s", *from, *to))
+	if path, err := g.ShortestPath(*from, *to); err != nil {
+		fmt.Fprintf(w, "  %v\n", err)
+	} else {
+		for _, e := range path {
+			fmt.Fprintf(w, "  %s -> %s : %s\n", e.From, e.To, e.Description)
+		}
+	}
+
+	if unreachable := unreachableStates(g); len(unreachable) > 0 {
+		section(w, "states unreachable from START")
+		items(w, unreachable)
+	}
+	return 0
+}
+
+// section prints a report heading.
+func section(w io.Writer, title string) {
+	fmt.Fprintf(w, "\n%s:\n", title)
+}
+
+// items prints one indented line per item, or "none".
+func items(w io.Writer, lines []string) {
+	if len(lines) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
+
+// unreachableStates returns the states no path from START leads to.
+func unreachableStates(g *graph.Graph) []string {
+	if _, ok := g.Nodes["START"]; !ok {
+		return nil
+	}
+	dom, err := g.Dominators("START")
+	if err != nil {
+		return nil
+	}
+	var states []string
+	for _, node := range g.NodeNames() {
+		if _, ok := dom[node]; !ok {
+			states = append(states, node)
+		}
+	}
+	return states
+}
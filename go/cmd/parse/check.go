@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	build "sqirvy.xyz/state-gen/internal/build"
+)
+
+// check verifies that the actions in a Go package generated with
+// gen -lang go only return keys of states that follow them in the diagram.
+func check(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Enable verbose logging output")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: parse check [-v] diagram package-directory\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+
+	g, err := loadGraph(fs.Arg(0), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %v\n", err)
+		return 1
+	}
+
+	diags, err := build.CheckGo(fs.Arg(1), g)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Check Error: %v\n", err)
+		return 1
+	}
+	for _, d := range diags {
+		fmt.Println(d)
+	}
+	if len(diags) > 0 {
+		return 1
+	}
+	return 0
+}
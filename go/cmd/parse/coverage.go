@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+	"sqirvy.xyz/state-gen/pkg/statemachine"
+)
+
+// coverage overlays transition counts recorded by statemachine.Coverage
+// onto a diagram and reports the edges and states that were never
+// exercised. Several coverage files can be given; their counts are merged.
+func coverage(args []string) int {
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Enable verbose logging output")
+	format := fs.String("format", "text", "Output format: text or mermaid")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: parse coverage [-format text|mermaid] diagram coverage.json...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return 2
+	}
+
+	g, err := loadGraph(fs.Arg(0), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %v\n", err)
+		return 1
+	}
+
+	cov := statemachine.NewCoverage()
+	for _, name := range fs.Args()[1:] {
+		c, err := readCoverageFile(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Coverage Error: %s: %v\n", name, err)
+			return 1
+		}
+		cov.Merge(c)
+	}
+
+	report := newCoverageReport(g, cov)
+	switch *format {
+	case "text":
+		report.writeText(os.Stdout)
+	case "mermaid":
+		report.writeMermaid(os.Stdout)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		return 2
+	}
+
+	if len(report.uncoveredEdges) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readCoverageFile reads a coverage file written by statemachine.WriteCoverage.
+func readCoverageFile(name string) (*statemachine.Coverage, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return statemachine.ReadCoverage(f)
+}
+
+// coverageReport is a diagram annotated with recorded transition counts.
+type coverageReport struct {
+	g              *graph.Graph
+	counts         map[statemachine.Transition]int
+	coveredStates  map[string]bool
+	uncoveredEdges []graph.Edge
+	// unknown lists recorded transitions that are not edges in the diagram
+	unknown []statemachine.Transition
+}
+
+// newCoverageReport matches the recorded counts against the diagram edges.
+// A state is covered once any recorded transition enters or leaves it.
+func newCoverageReport(g *graph.Graph, cov *statemachine.Coverage) *coverageReport {
+	r := &coverageReport{
+		g:             g,
+		counts:        cov.Counts(),
+		coveredStates: make(map[string]bool),
+	}
+
+	known := make(map[statemachine.Transition]bool)
+	for _, node := range g.NodeNames() {
+		for _, e := range g.Nodes[node] {
+			t := statemachine.Transition{From: statemachine.StateKey(e.From), To: statemachine.StateKey(e.To)}
+			known[t] = true
+			if r.counts[t] == 0 {
+				r.uncoveredEdges = append(r.uncoveredEdges, e)
+				continue
+			}
+			r.coveredStates[e.From] = true
+			r.coveredStates[e.To] = true
+		}
+	}
+
+	for t, n := range r.counts {
+		if n > 0 && !known[t] {
+			r.unknown = append(r.unknown, t)
+		}
+	}
+	sort.Slice(r.unknown, func(i, j int) bool {
+		if r.unknown[i].From != r.unknown[j].From {
+			return r.unknown[i].From < r.unknown[j].From
+		}
+		return r.unknown[i].To < r.unknown[j].To
+	})
+	return r
+}
+
+// uncoveredStates returns the diagram states no recorded transition touched.
+func (r *coverageReport) uncoveredStates() []string {
+	var states []string
+	for _, node := range r.g.NodeNames() {
+		if !r.coveredStates[node] {
+			states = append(states, node)
+		}
+	}
+	return states
+}
+
+// edgeCount returns the recorded count for a diagram edge.
+func (r *coverageReport) edgeCount(e graph.Edge) int {
+	return r.counts[statemachine.Transition{From: statemachine.StateKey(e.From), To: statemachine.StateKey(e.To)}]
+}
+
+// writeText prints every edge with its count followed by summaries of
+// what was not covered.
+func (r *coverageReport) writeText(w io.Writer) {
+	total := 0
+	for _, node := range r.g.NodeNames() {
+		for _, e := range r.g.Nodes[node] {
+			total++
+			fmt.Fprintf(w, "%6d  %s -> %s : %s\n", r.edgeCount(e), e.From, e.To, e.Description)
+		}
+	}
+	fmt.Fprintf(w, "\nedges covered: %d/%d\n", total-len(r.uncoveredEdges), total)
+
+	if states := r.uncoveredStates(); len(states) > 0 {
+		fmt.Fprintf(w, "uncovered states: %s\n", strings.Join(states, ", "))
+	}
+	for _, e := range r.uncoveredEdges {
+		fmt.Fprintf(w, "uncovered edge: %s -> %s : %s\n", e.From, e.To, e.Description)
+	}
+	for _, t := range r.unknown {
+		fmt.Fprintf(w, "transition not in diagram: %s -> %s\n", t.From, t.To)
+	}
+}
+
+// writeMermaid prints the diagram with each edge labeled by its count and
+// the states colored by whether they were covered.
+func (r *coverageReport) writeMermaid(w io.Writer) {
+	fmt.Fprintln(w, "stateDiagram-v2")
+	fmt.Fprintln(w, "    classDef covered fill:#2e7d32,color:#ffffff")
+	fmt.Fprintln(w, "    classDef uncovered fill:#c62828,color:#ffffff")
+	for _, node := range r.g.NodeNames() {
+		for _, e := range r.g.Nodes[node] {
+			labeled := e
+			labeled.Description = fmt.Sprintf("(%d)", r.edgeCount(e))
+			if e.Description != "-" {
+				labeled.Description = e.Description + " " + labeled.Description
+			}
+			fmt.Fprintf(w, "    %s\n", graph.MermaidTransition(labeled))
+		}
+	}
+
+	var covered, uncovered []string
+	for _, node := range r.g.NodeNames() {
+		if node == "START" || node == "END" {
+			continue
+		}
+		if r.coveredStates[node] {
+			covered = append(covered, node)
+		} else {
+			uncovered = append(uncovered, node)
+		}
+	}
+	if len(covered) > 0 {
+		fmt.Fprintf(w, "    class %s covered\n", strings.Join(covered, ", "))
+	}
+	if len(uncovered) > 0 {
+		fmt.Fprintf(w, "    class %s uncovered\n", strings.Join(uncovered, ", "))
+	}
+}
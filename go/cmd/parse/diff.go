@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// Exit codes of the diff subcommand, so CI can tell additive changes from
+// ones that break code generated from the old diagram.
+const (
+	diffSame     = 0
+	diffChanged  = 1
+	diffBreaking = 2
+	diffError    = 3
+)
+
+// diff reports the structural differences between two diagrams.
+func diff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	verbose := fs.Bool("v", false, "Enable verbose logging output")
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: parse diff [-format text|json] old-diagram new-diagram\n")
+		fmt.Fprintf(fs.Output(), "exit status: 0 no changes, 1 changes, 2 breaking changes (removed states, transitions, events, guards or actions), 3 error\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return diffError
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return diffError
+	}
+
+	oldGraph, err := loadGraph(fs.Arg(0), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %s: %v\n", fs.Arg(0), err)
+		return diffError
+	}
+	newGraph, err := loadGraph(fs.Arg(1), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %s: %v\n", fs.Arg(1), err)
+		return diffError
+	}
+
+	d := graph.Compare(oldGraph, newGraph)
+	switch *format {
+	case "text":
+		writeDiffText(os.Stdout, d)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil {
+			fmt.Fprintf(os.Stderr, "Output Error: %v\n", err)
+			return diffError
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		return diffError
+	}
+
+	switch {
+	case d.Breaking():
+		return diffBreaking
+	case !d.Empty():
+		return diffChanged
+	}
+	return diffSame
+}
+
+// writeDiffText prints one change per line, prefixed with + for additions,
+// - for removals and ~ for description changes, followed by the events,
+// guards and transition actions no longer used.
+func writeDiffText(w io.Writer, d *graph.Diff) {
+	for _, s := range d.RemovedStates {
+		fmt.Fprintf(w, "- state %s\n", s)
+	}
+	for _, s := range d.AddedStates {
+		fmt.Fprintf(w, "+ state %s\n", s)
+	}
+	for _, e := range d.RemovedEdges {
+		fmt.Fprintf(w, "- %s -> %s : %s\n", e.From, e.To, e.Description)
+	}
+	for _, e := range d.AddedEdges {
+		fmt.Fprintf(w, "+ %s -> %s : %s\n", e.From, e.To, e.Description)
+	}
+	for _, c := range d.ChangedDescriptions {
+		fmt.Fprintf(w, "~ %s -> %s : %s => %s\n", c.From, c.To, c.Old, c.New)
+	}
+	for _, s := range d.RemovedEvents {
+		fmt.Fprintf(w, "- event %s\n", s)
+	}
+	for _, s := range d.RemovedGuards {
+		fmt.Fprintf(w, "- guard %s\n", s)
+	}
+	for _, s := range d.RemovedActions {
+		fmt.Fprintf(w, "- action %s\n", s)
+	}
+	if d.Breaking() {
+		fmt.Fprintln(w, "breaking: states, transitions, events, guards or actions were removed")
+	}
+}
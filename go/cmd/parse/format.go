@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	parser "sqirvy.xyz/state-gen/pkg/parser"
+)
+
+// format normalizes diagram files in place, like gofmt -w. With no files
+// it formats stdin to stdout. With -l it only lists the files whose
+// formatting differs.
+func format(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	list := fs.Bool("l", false, "List files whose formatting differs instead of rewriting them")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: parse fmt [-l] [file...]\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
+			return 1
+		}
+		os.Stdout.Write(parser.Format(src))
+		return 0
+	}
+
+	exitCode := 0
+	for _, name := range fs.Args() {
+		if err := formatFile(name, *list); err != nil {
+			fmt.Fprintf(os.Stderr, "Format Error: %v\n", err)
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
+
+// formatFile rewrites a single file if its formatting differs. When list
+// is set the file name is printed instead.
+func formatFile(name string, list bool) error {
+	src, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	formatted := parser.Format(src)
+	if bytes.Equal(src, formatted) {
+		return nil
+	}
+	if list {
+		fmt.Println(name)
+		return nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, formatted, info.Mode().Perm())
+}
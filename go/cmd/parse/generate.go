@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	build "sqirvy.xyz/state-gen/internal/build"
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// generateOptions are the flags shared by every code generation backend.
+type generateOptions struct {
+	name   string
+	dir    string
+	source string
+	// pkg, model, input, events, completions and errors are only used by
+	// the go backend
+	pkg         string
+	model       string
+	input       string
+	events      bool
+	completions bool
+	errors      bool
+}
+
+// backend generates the files for one target language and returns them
+// keyed by file name.
+type backend func(g *graph.Graph, opts generateOptions) (map[string][]byte, error)
+
+// backends maps -lang values to code generators.
+var backends = map[string]backend{
+	"c":   generateC,
+	"c++": generateCpp,
+	"go":  generateGo,
+}
+
+// generate writes state machine scaffolding for a diagram in the language
+// selected with -lang.
+func generate(args []string) int {
+	fs := flag.NewFlagSet("gen", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Enable verbose logging output")
+	lang := fs.String("lang", "c", "Target `language`: c, c++ or go")
+	name := fs.String("name", "", "Machine name (default: diagram file name)")
+	dir := fs.String("o", ".", "Output `directory`")
+	pkg := fs.String("pkg", "main", "Package name for -lang go")
+	model := fs.String("model", "Model", "Model type name for -lang go")
+	input := fs.String("input", "Input", "Input type name for -lang go")
+	events := fs.Bool("events", false, "Generate an event table from the transition descriptions for -lang go")
+	completions := fs.Bool("completions", false, "Make unlabelled transitions completion transitions for -lang go")
+	errorStates := fs.Bool("errors", false, "Make transitions labelled error into error transitions for -lang go")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: parse gen [-lang language] [-name name] [-o directory] [go flags] diagram\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	gen, ok := backends[*lang]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown language %q\n", *lang)
+		return 2
+	}
+
+	g, err := loadGraph(fs.Arg(0), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %v\n", err)
+		return 1
+	}
+
+	opts := generateOptions{
+		name:        *name,
+		dir:         *dir,
+		source:      filepath.Base(fs.Arg(0)),
+		pkg:         *pkg,
+		model:       *model,
+		input:       *input,
+		events:      *events,
+		completions: *completions,
+		errors:      *errorStates,
+	}
+	if opts.name == "" {
+		base := filepath.Base(fs.Arg(0))
+		opts.name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	files, err := gen(g, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Generate Error: %v\n", err)
+		return 1
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(opts.dir, name)
+		if err := os.WriteFile(path, files[name], 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Write Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(path)
+	}
+	return 0
+}
+
+// generateC emits a header and source file for c/include/state_machine.h.
+func generateC(g *graph.Graph, opts generateOptions) (map[string][]byte, error) {
+	files, err := build.GenerateC(opts.name, g)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		files.HeaderName: files.Header,
+		files.SourceName: files.Source,
+	}, nil
+}
+
+// generateCpp emits a setup header for c++/include/state_machine.hpp and
+// a GoogleTest skeleton.
+func generateCpp(g *graph.Graph, opts generateOptions) (map[string][]byte, error) {
+	files, err := build.GenerateCpp(opts.name, g)
+	if err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		files.HeaderName: files.Header,
+		files.TestName:   files.Test,
+	}, nil
+}
+
+// generateGo emits a Go file with typed state keys for pkg/statemachine.
+// If the file already exists the diagram is merged into it, keeping the
+// hand written action bodies.
+func generateGo(g *graph.Graph, opts generateOptions) (map[string][]byte, error) {
+	goOpts := build.GoOptions{
+		Package:     opts.pkg,
+		Name:        opts.name,
+		Source:      opts.source,
+		Model:       opts.model,
+		Input:       opts.input,
+		Events:      opts.events,
+		Completions: opts.completions,
+		Errors:      opts.errors,
+	}
+	name := build.GoFileName(opts.name)
+
+	existing, err := os.ReadFile(filepath.Join(opts.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		src, err := build.GenerateGo(goOpts, g)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{name: src}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	src, removed, err := build.MergeGo(existing, goOpts, g)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range removed {
+		fmt.Fprintf(os.Stderr, "state %s is no longer in the diagram; its action in %s is marked REMOVED\n", state, name)
+	}
+	return map[string][]byte{name: src}, nil
+}
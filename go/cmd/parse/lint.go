@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// lint reports duplicate transitions, ambiguous events and malformed
+// labels in a diagram.
+// It exits with status 1 if any problem has error severity.
+func lint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Enable verbose logging output")
+	duplicates := fs.String("duplicates", "warning", "Severity of duplicate transitions: ignore, warning or error")
+	ambiguous := fs.String("ambiguous", "warning", "Severity of events that lead from one state to different states: ignore, warning or error")
+	labels := fs.String("labels", "warning", "Severity of labels that are not \"event [guard] / action\": ignore, warning or error")
+	strict := fs.Bool("strict", false, "Treat every problem as an error; cannot be combined with the severity flags")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: parse lint [flags] diagram\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	if *strict {
+		conflict := false
+		fs.Visit(func(f *flag.Flag) {
+			conflict = conflict || f.Name == "duplicates" || f.Name == "ambiguous" || f.Name == "labels"
+		})
+		if conflict {
+			fmt.Fprintf(os.Stderr, "-strict cannot be combined with -duplicates, -ambiguous or -labels\n")
+			return 2
+		}
+	}
+
+	checks := graph.StrictChecks
+	if !*strict {
+		var err error
+		if checks.Duplicates, err = graph.ParseSeverity(*duplicates); err != nil {
+			fmt.Fprintf(os.Stderr, "-duplicates: %v\n", err)
+			return 2
+		}
+		if checks.Ambiguous, err = graph.ParseSeverity(*ambiguous); err != nil {
+			fmt.Fprintf(os.Stderr, "-ambiguous: %v\n", err)
+			return 2
+		}
+		if checks.Labels, err = graph.ParseSeverity(*labels); err != nil {
+			fmt.Fprintf(os.Stderr, "-labels: %v\n", err)
+			return 2
+		}
+	}
+
+	g, err := loadGraph(fs.Arg(0), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %v\n", err)
+		return 1
+	}
+
+	exitCode := 0
+	for _, p := range g.Lint(checks) {
+		fmt.Println(p)
+		if p.Severity == graph.SeverityError {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
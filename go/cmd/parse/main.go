@@ -4,16 +4,48 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
-	graph "sqirvy.xyz/state-gen/internal/graph"
-	parser "sqirvy.xyz/state-gen/internal/parser"
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+	parser "sqirvy.xyz/state-gen/pkg/parser"
 )
 
+// command is a parse subcommand. It receives the arguments that follow the
+// subcommand name and returns the process exit code.
+type command func(args []string) int
+
+// commands maps subcommand names to their implementations. Invoking parse
+// without a subcommand prints the graph, as it always has.
+var commands = map[string]command{
+	"analyze":  analyze,
+	"check":    check,
+	"coverage": coverage,
+	"diff":     diff,
+	"fmt":      format,
+	"gen":      generate,
+	"lint":     lint,
+	"paths":    paths,
+	"product":  product,
+	"simulate": simulate,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+
 	// Define command line flags
 	verbose := flag.Bool("v", false, "Enable verbose logging output")
+	strict := flag.Bool("strict", false, "Reject duplicate transitions, ambiguous events and malformed labels")
+	minimize := flag.Bool("minimize", false, "Print the minimal equivalent diagram as Mermaid; merged states are reported on stderr")
 	flag.Parse()
 
+	if *minimize {
+		os.Exit(minimizeGraph(flag.Arg(0), *verbose, *strict))
+	}
+
 	exitCode := 0
 	var input = os.Stdin
 	var err error
@@ -38,7 +70,11 @@ func main() {
 
 	// Print valid results to stdout
 	g := graph.NewGraph()
-	err = g.Load(validResults)
+	if *strict {
+		err = g.LoadStrict(validResults)
+	} else {
+		err = g.Load(validResults)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Load Error: %v\n", err)
 		os.Exit(1)
@@ -49,3 +85,71 @@ func main() {
 	// Exit with the appropriate exit code
 	os.Exit(exitCode)
 }
+
+// minimizeGraph prints the minimal equivalent of the named diagram, or of
+// stdin if filename is empty, and returns the exit code. Unlike the
+// default mode it reads the diagram like the subcommands do, so markdown
+// around a ```mermaid fence is skipped.
+func minimizeGraph(filename string, verbose, strict bool) int {
+	var opts []graph.Option
+	if strict {
+		opts = append(opts, graph.Strict())
+	}
+	g, err := loadGraph(filename, verbose, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %v\n", err)
+		return 1
+	}
+	minimal, merges, err := g.Minimize()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Minimize Error: %v\n", err)
+		return 1
+	}
+	merged := make(map[string]bool)
+	for _, m := range merges {
+		fmt.Fprintf(os.Stderr, "merged %s into %s\n", strings.Join(m.Merged, ", "), m.State)
+		for _, node := range m.Merged {
+			merged[node] = true
+		}
+	}
+	var dropped []string
+	for _, node := range g.NodeNames() {
+		if _, ok := minimal.Nodes[node]; !ok && !merged[node] {
+			dropped = append(dropped, node)
+		}
+	}
+	if len(dropped) > 0 {
+		fmt.Fprintf(os.Stderr, "dropped %s, unreachable from START\n", strings.Join(dropped, ", "))
+	}
+	fmt.Print(minimal.Mermaid())
+	return 0
+}
+
+// loadGraph parses the named Mermaid file, or stdin if filename is empty,
+// and loads the transitions into a graph built with opts. Lines that are
+// not transitions (fences, config, comments) are skipped; they are logged
+// to stderr when verbose is set.
+func loadGraph(filename string, verbose bool, opts ...graph.Option) (*graph.Graph, error) {
+	input := os.Stdin
+	if filename != "" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, fmt.Errorf("opening file: %w", err)
+		}
+		defer f.Close()
+		input = f
+	}
+
+	d, err := parser.Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		for _, skipped := range d.Skipped {
+			fmt.Fprintln(os.Stderr, skipped)
+		}
+	}
+
+	g, _, err := graph.FromDiagram(d, opts...)
+	return g, err
+}
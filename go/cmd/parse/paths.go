@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	build "sqirvy.xyz/state-gen/internal/build"
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// pathsOutput is the JSON form of a single generated path.
+type pathsOutput struct {
+	Name   string       `json:"name"`
+	States []string     `json:"states"`
+	Edges  []graph.Edge `json:"edges"`
+}
+
+// paths generates test scenarios from a diagram: either a set of START to
+// END paths that covers every edge, or every simple path up to a bound.
+func paths(args []string) int {
+	fs := flag.NewFlagSet("paths", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Enable verbose logging output")
+	mode := fs.String("mode", "edge", "Path selection: `edge` coverage or simple paths")
+	maxEdges := fs.Int("max", 0, "Maximum edges per simple path (0 means no bound)")
+	format := fs.String("format", "json", "Output format: json or go")
+	pkg := fs.String("pkg", "main", "Package name for -format go")
+	name := fs.String("name", "", "Machine name for -format go (default: diagram file name)")
+	model := fs.String("model", "Model", "Model type name for -format go")
+	input := fs.String("input", "Input", "Input type name for -format go")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: parse paths [flags] diagram\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	g, err := loadGraph(fs.Arg(0), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %v\n", err)
+		return 1
+	}
+
+	var result []graph.Path
+	switch *mode {
+	case "edge":
+		var uncovered []graph.Edge
+		result, uncovered, err = g.EdgeCoverPaths("START", "END")
+		for _, e := range uncovered {
+			fmt.Fprintf(os.Stderr, "edge not on any START to END path: %s -> %s : %s\n", e.From, e.To, e.Description)
+		}
+	case "simple":
+		result, err = g.SimplePaths("START", "END", *maxEdges)
+	default:
+		err = fmt.Errorf("unknown mode %q", *mode)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Paths Error: %v\n", err)
+		return 1
+	}
+
+	switch *format {
+	case "json":
+		out := make([]pathsOutput, 0, len(result))
+		for i, p := range result {
+			out = append(out, pathsOutput{
+				Name:   fmt.Sprintf("path %d", i+1),
+				States: p.States(),
+				Edges:  p,
+			})
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			fmt.Fprintf(os.Stderr, "Output Error: %v\n", err)
+			return 1
+		}
+	case "go":
+		if *name == "" {
+			base := filepath.Base(fs.Arg(0))
+			*name = strings.TrimSuffix(base, filepath.Ext(base))
+		}
+		src, err := build.PathTests(build.PathTestOptions{
+			Package: *pkg,
+			Name:    *name,
+			Model:   *model,
+			Input:   *input,
+		}, result)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Output Error: %v\n", err)
+			return 1
+		}
+		os.Stdout.Write(src)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *format)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// simulate walks a diagram from START to END, letting the user choose each
+// transition by number or by description. With -script the choices are read
+// from a file, one per line, and any mismatch is fatal.
+func simulate(args []string) int {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Enable verbose logging output")
+	script := fs.String("script", "", "Read transition choices from `file` instead of stdin")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: parse simulate [-v] [-script file] diagram\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	g, err := loadGraph(fs.Arg(0), *verbose)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Load Error: %v\n", err)
+		return 1
+	}
+
+	in := io.Reader(os.Stdin)
+	if *script != "" {
+		f, err := os.Open(*script)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening script: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	s := newSimulator(g, in, os.Stdout, *script != "")
+	if err := s.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Simulation Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// simulator steps through a graph one transition at a time.
+type simulator struct {
+	g        *graph.Graph
+	in       *bufio.Scanner
+	out      io.Writer
+	scripted bool
+	path     []string
+}
+
+// newSimulator creates a simulator positioned at START. When scripted is
+// true an unrecognized choice ends the run with an error instead of
+// prompting again.
+func newSimulator(g *graph.Graph, in io.Reader, out io.Writer, scripted bool) *simulator {
+	return &simulator{
+		g:        g,
+		in:       bufio.NewScanner(in),
+		out:      out,
+		scripted: scripted,
+		path:     []string{"START"},
+	}
+}
+
+// current returns the state the simulation is in.
+func (s *simulator) current() string {
+	return s.path[len(s.path)-1]
+}
+
+// run drives the simulation until END is reached, input is exhausted,
+// or the walk gets stuck in a state with no outgoing transitions.
+func (s *simulator) run() error {
+	if _, ok := s.g.Nodes["START"]; !ok {
+		return fmt.Errorf("graph has no START state")
+	}
+
+	for s.current() != "END" {
+		edges := s.g.Nodes[s.current()]
+		if len(edges) == 0 {
+			return fmt.Errorf("state %s has no outgoing transitions", s.current())
+		}
+		s.show(edges)
+
+		edge, err := s.choose(edges)
+		if err != nil {
+			return err
+		}
+		s.path = append(s.path, edge.To)
+	}
+
+	fmt.Fprintf(s.out, "path: %s\n", strings.Join(s.path, " -> "))
+	fmt.Fprintln(s.out, "reached END")
+	return nil
+}
+
+// show prints the path so far and the transitions available from the
+// current state.
+func (s *simulator) show(edges []graph.Edge) {
+	fmt.Fprintf(s.out, "path: %s\n", strings.Join(s.path, " -> "))
+	fmt.Fprintf(s.out, "state: %s\n", s.current())
+	for i, e := range edges {
+		fmt.Fprintf(s.out, "  %d) %s -> %s : %s\n", i+1, e.From, e.To, e.Description)
+	}
+}
+
+// choose reads lines until one selects a transition. Interactive runs
+// prompt again after a bad choice; scripted runs fail.
+func (s *simulator) choose(edges []graph.Edge) (*graph.Edge, error) {
+	for {
+		if !s.scripted {
+			fmt.Fprint(s.out, "> ")
+		}
+		if !s.in.Scan() {
+			if err := s.in.Err(); err != nil {
+				return nil, fmt.Errorf("reading input: %w", err)
+			}
+			return nil, fmt.Errorf("input ended in state %s before END", s.current())
+		}
+
+		choice := strings.TrimSpace(s.in.Text())
+		if choice == "" {
+			continue
+		}
+		if edge := match(edges, choice); edge != nil {
+			if s.scripted {
+				fmt.Fprintf(s.out, "> %s\n", choice)
+			}
+			return edge, nil
+		}
+		if s.scripted {
+			return nil, fmt.Errorf("no transition %q from state %s", choice, s.current())
+		}
+		fmt.Fprintf(s.out, "no transition %q from state %s\n", choice, s.current())
+	}
+}
+
+// match finds the edge selected by choice, either as a 1-based index
+// into edges or as an exact description.
+func match(edges []graph.Edge, choice string) *graph.Edge {
+	if n, err := strconv.Atoi(choice); err == nil {
+		if n >= 1 && n <= len(edges) {
+			return &edges[n-1]
+		}
+		return nil
+	}
+	for i := range edges {
+		if edges[i].Description == choice {
+			return &edges[i]
+		}
+	}
+	return nil
+}
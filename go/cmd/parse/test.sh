@@ -1,5 +1,25 @@
-#!/bin/sh
+#!/bin/bash
 
+# every command must succeed unless its status is checked with expect
+set -euo pipefail
+
+# go run reports every failure as exit status 1, so commands whose exit
+# status is checked use a built binary
+parse="$(mktemp -d)/parse"
+go build -o "$parse" .
+
+# expect runs a command and stops the script unless it exits with the
+# given status
+expect() {
+    want=$1
+    shift
+    got=0
+    "$@" || got=$?
+    if [ "$got" -ne "$want" ]; then
+        echo "FAIL: $* exited with $got, want $want" >&2
+        exit 1
+    fi
+}
 
 echo "State1a --> State2a" | go run . -v
 echo "State1b --> State2b : description text" | go run . -v
@@ -19,5 +39,25 @@ echo "State1j --> [*] :description text" | go run . -v
 echo "A --> B: :.,?!@=~" | go run . -v
 echo "A --> B::.,?!@=~" | go run . -v
 echo "A --> B: : .,?!@=~" | go run . -v
-echo "the following test should fail"
-echo "123" | go run . -v || true
+# the input has no transition
+echo "123" | expect 1 "$parse" -v
+go run . simulate -script ../../../test/order-script.txt ../../../test/order.md
+go run . paths -format go ../../../test/order.md
+# the recorded run leaves transitions uncovered
+expect 1 "$parse" coverage ../../../test/order.md ../../../test/order-coverage.json
+go run . fmt < ../../../test/t3.md
+go run . gen -lang c -o "$(mktemp -d)" ../../../test/order.md
+go run . gen -lang c++ -o "$(mktemp -d)" ../../../test/order.md
+gendir="$(mktemp -d)"
+go run . gen -lang go -pkg order -o "$gendir" ../../../test/order.md
+go run . check ../../../test/order.md "$gendir"
+go run . gen -lang go -pkg order -o "$gendir" ../../../test/order.md
+# the diagrams differ
+expect 1 "$parse" diff ../../../test/t1.md ../../../test/t2.md
+go run . analyze ../../../test/s1.md
+go run . lint -strict ../../../test/order.md
+go run . gen -lang go -events -input sm.Event -pkg order -o "$(mktemp -d)" ../../../test/order.md
+go run . gen -lang go -events -completions -input sm.Event -pkg order -o "$(mktemp -d)" ../../../test/order.md
+go run . gen -lang go -errors -pkg order -o "$(mktemp -d)" ../../../test/order.md
+go run . -minimize ../../../test/order.md
+go run . product ../../../test/client.md ../../../test/server.md
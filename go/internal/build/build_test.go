@@ -1,6 +1,7 @@
 package build
 
 import (
+	"errors"
 	"testing"
 
 	sm "sqirvy.xyz/state-gen/pkg/statemachine"
@@ -57,21 +58,23 @@ var statex = []*sm.State[XModel, XInput]{
 
 func TestStateMachine(t *testing.T) {
 
-	sm := sm.NewStateMachine[XModel, XInput](&XModel{}, "test")
+	machine := sm.NewStateMachine[XModel, XInput](&XModel{}, "test")
 	for _, s := range statex {
-		if err := sm.AddState(s); err != nil {
+		if err := machine.AddState(s); err != nil {
 			t.Errorf("unexpected error: %s", err)
 		}
 	}
+	if err := machine.SetFinalStates("END"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
 
-	for {
-		key, err := sm.Execute(&XModel{}, XInput(1))
-		if err != nil {
+	for !machine.Done() {
+		if _, err := machine.Execute(&XModel{}, XInput(1)); err != nil {
 			t.Fatalf("unexpected error during execution: %s", err)
 		}
-		if key == "END" {
-			break
-		}
+	}
+	if _, err := machine.Execute(&XModel{}, XInput(1)); !errors.Is(err, sm.ErrFinished) {
+		t.Errorf("Execute() after END error = %v, want ErrFinished", err)
 	}
 
 }
@@ -0,0 +1,156 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// CFiles is the output of the C backend: a header and a source file that
+// build a state machine using c/include/state_machine.h.
+type CFiles struct {
+	// HeaderName is the file name of the header, e.g. order_machine.h
+	HeaderName string
+	Header     []byte
+	// SourceName is the file name of the source, e.g. order_machine.c
+	SourceName string
+	Source     []byte
+}
+
+// GenerateC generates a C scaffold for the graph. Every node gets a key
+// constant and a stub action that returns the key of its first outgoing
+// edge, or its own key if it has none. build_<name>_machine() registers
+// every state and starts the machine in START if the graph has one.
+func GenerateC(name string, g *graph.Graph) (*CFiles, error) {
+	base := snakeName(name)
+	if base == "" {
+		return nil, fmt.Errorf("invalid machine name %q", name)
+	}
+	nodes := nodeOrder(g)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("graph has no states")
+	}
+
+	// key constants are upper case, so distinct states may collide
+	consts := make(map[string]string, len(nodes))
+	seen := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		c := strings.ToUpper(base + "_" + node)
+		if other, ok := seen[c]; ok {
+			return nil, fmt.Errorf("states %s and %s both map to %s", other, node, c)
+		}
+		seen[c] = node
+		consts[node] = c
+	}
+
+	files := &CFiles{
+		HeaderName: base + "_machine.h",
+		SourceName: base + "_machine.c",
+	}
+	guard := strings.ToUpper(base) + "_MACHINE_H"
+
+	var h strings.Builder
+	fmt.Fprintf(&h, "#ifndef %s\n#define %s\n\n", guard, guard)
+	h.WriteString("#include \"state_machine.h\"\n\n")
+	fmt.Fprintf(&h, "// State keys for the %s machine\n", base)
+	for _, node := range nodes {
+		fmt.Fprintf(&h, "extern const StateKey %s;\n", consts[node])
+	}
+	fmt.Fprintf(&h, "\n// build_%s_machine creates the %s state machine with all states registered.\n", base, base)
+	h.WriteString("// It returns NULL if any allocation fails.\n")
+	fmt.Fprintf(&h, "StateMachine* build_%s_machine(void);\n\n", base)
+	fmt.Fprintf(&h, "#endif // %s\n", guard)
+	files.Header = []byte(h.String())
+
+	var c strings.Builder
+	fmt.Fprintf(&c, "#include <stddef.h>\n#include \"%s\"\n\n", files.HeaderName)
+	for _, node := range nodes {
+		fmt.Fprintf(&c, "const StateKey %s = %q;\n", consts[node], node)
+	}
+	c.WriteString("\n")
+
+	for _, node := range nodes {
+		edges := g.Nodes[node]
+		next := consts[node]
+		if len(edges) > 0 {
+			next = consts[edges[0].To]
+		}
+		fmt.Fprintf(&c, "static StateKey %s_%s_action(const State* state, Model* model, const void* input) {\n", base, strings.ToLower(node))
+		c.WriteString("    // COMMENT\n")
+		for _, e := range edges {
+			fmt.Fprintf(&c, "    // %s\n", graph.MermaidTransition(e))
+		}
+		fmt.Fprintf(&c, "    return %s;\n}\n\n", next)
+	}
+
+	fmt.Fprintf(&c, "StateMachine* build_%s_machine(void) {\n", base)
+	fmt.Fprintf(&c, "    StateMachine* sm = new_state_machine(%q);\n", base)
+	c.WriteString("    if (!sm) return NULL;\n\n")
+	c.WriteString("    State* states[] = {\n")
+	for _, node := range nodes {
+		fmt.Fprintf(&c, "        new_state(%s, %s_%s_action, NULL),\n", consts[node], base, strings.ToLower(node))
+	}
+	c.WriteString("    };\n")
+	c.WriteString("    size_t num_states = sizeof(states) / sizeof(states[0]);\n\n")
+	c.WriteString("    for (size_t i = 0; i < num_states; i++) {\n")
+	c.WriteString("        if (add_state(sm, states[i]) != 0) {\n")
+	c.WriteString("            for (size_t j = i; j < num_states; j++) {\n")
+	c.WriteString("                free_state(states[j]);\n")
+	c.WriteString("            }\n")
+	c.WriteString("            free_state_machine(sm);\n")
+	c.WriteString("            return NULL;\n")
+	c.WriteString("        }\n")
+	c.WriteString("    }\n")
+	if _, ok := g.Nodes["START"]; ok {
+		fmt.Fprintf(&c, "\n    if (set_initial_state(sm, %s) != 0) {\n", consts["START"])
+		c.WriteString("        free_state_machine(sm);\n")
+		c.WriteString("        return NULL;\n")
+		c.WriteString("    }\n")
+	}
+	c.WriteString("\n    return sm;\n}\n")
+	files.Source = []byte(c.String())
+
+	return files, nil
+}
+
+// nodeOrder returns the graph's nodes with START first, END last and the
+// rest sorted, the order every backend declares states in.
+func nodeOrder(g *graph.Graph) []string {
+	var nodes []string
+	if _, ok := g.Nodes["START"]; ok {
+		nodes = append(nodes, "START")
+	}
+	for _, node := range g.NodeNames() {
+		if node != "START" && node != "END" {
+			nodes = append(nodes, node)
+		}
+	}
+	if _, ok := g.Nodes["END"]; ok {
+		nodes = append(nodes, "END")
+	}
+	return nodes
+}
+
+// snakeName converts a diagram or file name into a lower case C
+// identifier, replacing runs of other characters with underscores.
+func snakeName(s string) string {
+	var sb strings.Builder
+	sep := false
+	for _, r := range s {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			sep = sb.Len() > 0
+			continue
+		}
+		if sb.Len() == 0 && unicode.IsDigit(r) {
+			continue
+		}
+		if sep {
+			sb.WriteByte('_')
+			sep = false
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
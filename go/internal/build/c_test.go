@@ -0,0 +1,147 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+const cdir = "../../../c/"
+
+// orderGraph is the diagram from test/order.md.
+func orderGraph(t *testing.T) *graph.Graph {
+	t.Helper()
+	g := graph.NewGraph()
+	err := g.Load([]string{
+		"START,Idle,-",
+		"Idle,Processing,submit",
+		"Processing,Idle,reject",
+		"Processing,Done,accept",
+		"Done,END,-",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	return g
+}
+
+func TestGenerateC(t *testing.T) {
+	files, err := GenerateC("order", orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateC() error = %v", err)
+	}
+	if files.HeaderName != "order_machine.h" || files.SourceName != "order_machine.c" {
+		t.Errorf("file names = %s, %s", files.HeaderName, files.SourceName)
+	}
+
+	for _, want := range []string{
+		"extern const StateKey ORDER_PROCESSING;",
+		"StateMachine* build_order_machine(void);",
+	} {
+		if !strings.Contains(string(files.Header), want) {
+			t.Errorf("header missing %q\n%s", want, files.Header)
+		}
+	}
+	for _, want := range []string{
+		`const StateKey ORDER_IDLE = "Idle";`,
+		"static StateKey order_processing_action(const State* state, Model* model, const void* input) {",
+		"    // Processing --> Idle : reject\n",
+		"    return ORDER_IDLE;\n",
+		"    return ORDER_END;\n",
+		"set_initial_state(sm, ORDER_START)",
+	} {
+		if !strings.Contains(string(files.Source), want) {
+			t.Errorf("source missing %q\n%s", want, files.Source)
+		}
+	}
+}
+
+func TestGenerateCErrors(t *testing.T) {
+	if _, err := GenerateC("123", orderGraph(t)); err == nil {
+		t.Error("expected error for invalid name")
+	}
+	if _, err := GenerateC("order", graph.NewGraph()); err == nil {
+		t.Error("expected error for empty graph")
+	}
+
+	g := graph.NewGraph()
+	g.AddEdge(&graph.Edge{From: "idle", To: "Idle", Description: "-"})
+	if _, err := GenerateC("order", g); err == nil {
+		t.Error("expected error for colliding state names")
+	}
+}
+
+// TestGenerateCCompiles builds the generated scaffold against the C
+// library and checks every stub returns a registered state key.
+func TestGenerateCCompiles(t *testing.T) {
+	cc, err := exec.LookPath("gcc")
+	if err != nil {
+		t.Skip("gcc not found")
+	}
+
+	files, err := GenerateC("order", orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateC() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	main := `#include "order_machine.h"
+
+int main(void) {
+    StateMachine* sm = build_order_machine();
+    Model model = {0};
+    int input = 0;
+    if (!sm) return 1;
+    for (int i = 0; i < 10; i++) {
+        if (!execute(sm, &model, &input)) return 1;
+    }
+    free_state_machine(sm);
+    return 0;
+}
+`
+	for name, src := range map[string][]byte{
+		files.HeaderName: files.Header,
+		files.SourceName: files.Source,
+		"main.c":         []byte(main),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), src, 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	bin := filepath.Join(dir, "order")
+	out, err := exec.Command(cc, "-Wall", "-Wextra", "-Wno-unused-parameter", "-Werror",
+		"-I", cdir+"include", "-o", bin,
+		cdir+"src/state_machine.c",
+		filepath.Join(dir, files.SourceName),
+		filepath.Join(dir, "main.c"),
+	).CombinedOutput()
+	if err != nil {
+		t.Fatalf("compile failed: %v\n%s", err, out)
+	}
+	if out, err := exec.Command(bin).CombinedOutput(); err != nil {
+		t.Fatalf("generated machine failed: %v\n%s", err, out)
+	}
+}
+
+func TestSnakeName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"order", "order"},
+		{"Order-Flow", "order_flow"},
+		{"my  machine.md", "my_machine_md"},
+		{"2fa", "fa"},
+		{"--x--", "x"},
+	}
+	for _, tt := range tests {
+		if got := snakeName(tt.in); got != tt.want {
+			t.Errorf("snakeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package build
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// Diagnostic is a problem found by CheckGo.
+type Diagnostic struct {
+	// Pos is the file:line:column of the problem, empty if it has no location
+	Pos string
+	// Message describes the problem
+	Message string
+}
+
+// String returns the diagnostic in the file:line:column: message form.
+func (d Diagnostic) String() string {
+	if d.Pos == "" {
+		return d.Message
+	}
+	return d.Pos + ": " + d.Message
+}
+
+// CheckGo verifies the actions in a generated Go package against the
+// diagram. States are found through their sm.NewState(key, action, ...)
+// or sm.NewContextState registrations. Every return statement in an action that returns a nil
+// error must name a key that is the state itself or one of its successors
+// in the diagram. Keys may be package level string constants or string
+// literals; bare returns of named results are not checked. Diagram states
+// with no registered action are reported too.
+func CheckGo(dir string, g *graph.Graph) ([]Diagnostic, error) {
+	fset := token.NewFileSet()
+	files, err := parseDir(fset, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &checker{
+		fset:   fset,
+		g:      g,
+		consts: make(map[string]string),
+		funcs:  make(map[string]*ast.FuncDecl),
+	}
+	for _, f := range files {
+		c.collect(f)
+	}
+
+	registered := make(map[string]bool)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || !isNewState(call) || len(call.Args) < 2 {
+				return true
+			}
+			state, ok := c.key(call.Args[0])
+			if !ok {
+				c.report(call.Args[0], "state key is not a string constant")
+				return true
+			}
+			registered[state] = true
+			if _, ok := g.Nodes[state]; !ok {
+				c.report(call.Args[0], fmt.Sprintf("state %s is not in the diagram", state))
+				return true
+			}
+			c.checkAction(state, call.Args[1])
+			return true
+		})
+	}
+
+	for _, node := range g.NodeNames() {
+		if !registered[node] {
+			c.diags = append(c.diags, Diagnostic{Message: fmt.Sprintf("state %s has no registered action", node)})
+		}
+	}
+	return c.diags, nil
+}
+
+// parseDir parses the non-test Go files in a directory.
+func parseDir(fset *token.FileSet, dir string) ([]*ast.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return files, nil
+}
+
+// checker holds the package wide declarations CheckGo resolves against.
+type checker struct {
+	fset *token.FileSet
+	g    *graph.Graph
+	// consts maps package level string constants to their values
+	consts map[string]string
+	// funcs maps package level function names to their declarations
+	funcs map[string]*ast.FuncDecl
+	diags []Diagnostic
+}
+
+// collect records the string constants and functions declared in a file.
+func (c *checker) collect(f *ast.File) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				c.funcs[d.Name.Name] = d
+			}
+		case *ast.GenDecl:
+			if d.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range d.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if i >= len(vs.Values) {
+						continue
+					}
+					if lit, ok := vs.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						if v, err := strconv.Unquote(lit.Value); err == nil {
+							c.consts[name.Name] = v
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// key resolves an expression to a state key if it is a string literal or
+// a package level string constant.
+func (c *checker) key(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			if v, err := strconv.Unquote(e.Value); err == nil {
+				return v, true
+			}
+		}
+	case *ast.Ident:
+		v, ok := c.consts[e.Name]
+		return v, ok
+	case *ast.ParenExpr:
+		return c.key(e.X)
+	case *ast.CallExpr:
+		// conversions such as sm.StateKey("Idle")
+		if len(e.Args) == 1 {
+			return c.key(e.Args[0])
+		}
+	}
+	return "", false
+}
+
+// checkAction checks the returns of the action registered for state.
+func (c *checker) checkAction(state string, action ast.Expr) {
+	var body *ast.BlockStmt
+	switch a := action.(type) {
+	case *ast.FuncLit:
+		body = a.Body
+	case *ast.Ident:
+		fn, ok := c.funcs[a.Name]
+		if !ok || fn.Body == nil {
+			c.report(action, fmt.Sprintf("action for state %s is not a function declared in the package", state))
+			return
+		}
+		body = fn.Body
+	default:
+		c.report(action, fmt.Sprintf("action for state %s cannot be checked", state))
+		return
+	}
+
+	successors := map[string]bool{state: true}
+	var allowed []string
+	for _, e := range c.g.Nodes[state] {
+		if !successors[e.To] {
+			allowed = append(allowed, e.To)
+		}
+		successors[e.To] = true
+	}
+	sort.Strings(allowed)
+
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch s := n.(type) {
+		case *ast.FuncLit:
+			// returns in nested functions do not return from the action
+			return false
+		case *ast.ReturnStmt:
+			if len(s.Results) != 2 || !isNil(s.Results[1]) {
+				return true
+			}
+			next, ok := c.key(s.Results[0])
+			if !ok {
+				c.report(s.Results[0], fmt.Sprintf("state %s returns a key that is not a state constant", state))
+				return true
+			}
+			if !successors[next] {
+				c.report(s.Results[0], fmt.Sprintf("state %s returns %s, which is not a successor (want one of %s)",
+					state, next, strings.Join(append([]string{state}, allowed...), ", ")))
+			}
+		}
+		return true
+	})
+}
+
+// report records a diagnostic at the position of node.
+func (c *checker) report(node ast.Node, msg string) {
+	c.diags = append(c.diags, Diagnostic{Pos: c.fset.Position(node.Pos()).String(), Message: msg})
+}
+
+// isNewState reports whether call is a call to NewState or NewContextState,
+// with or without a package qualifier.
+func isNewState(call *ast.CallExpr) bool {
+	fun := call.Fun
+	if idx, ok := fun.(*ast.IndexListExpr); ok {
+		fun = idx.X
+	}
+	if idx, ok := fun.(*ast.IndexExpr); ok {
+		fun = idx.X
+	}
+	switch f := fun.(type) {
+	case *ast.Ident:
+		return f.Name == "NewState" || f.Name == "NewContextState"
+	case *ast.SelectorExpr:
+		return f.Sel.Name == "NewState" || f.Sel.Name == "NewContextState"
+	}
+	return false
+}
+
+// isNil reports whether expr is the nil identifier.
+func isNil(expr ast.Expr) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == "nil"
+}
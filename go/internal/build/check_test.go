@@ -0,0 +1,92 @@
+package build
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckGenerated(t *testing.T) {
+	g := orderGraph(t)
+	src, err := GenerateGo(orderOptions, g)
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+
+	diags, err := CheckGo(writeGoPackage(t, src), g)
+	if err != nil {
+		t.Fatalf("CheckGo() error = %v", err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("unexpected diagnostics: %v", diags)
+	}
+}
+
+func TestCheckReturns(t *testing.T) {
+	g := orderGraph(t)
+	src, err := GenerateGo(orderOptions, g)
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+
+	// Idle may only stay or move to Processing
+	edited := strings.Replace(string(src), `	// Idle --> Processing : submit
+	return OrderProcessing, nil`, `	// Idle --> Processing : submit
+	if input > 0 {
+		return "Done", nil
+	}
+	if input < 0 {
+		return "", errors.New("negative input")
+	}
+	next := OrderIdle
+	return next, nil`, 1)
+	edited = strings.Replace(edited, "import (\n", "import (\n\t\"errors\"\n", 1)
+	if edited == string(src) {
+		t.Fatal("failed to edit generated source")
+	}
+
+	diags, err := CheckGo(writeGoPackage(t, []byte(edited)), g)
+	if err != nil {
+		t.Fatalf("CheckGo() error = %v", err)
+	}
+
+	want := []string{
+		"state Idle returns Done, which is not a successor (want one of Idle, Processing)",
+		"state Idle returns a key that is not a state constant",
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("got diagnostics %v, want %v", diags, want)
+	}
+	for i, d := range diags {
+		if d.Message != want[i] {
+			t.Errorf("diagnostic %d = %q, want %q", i, d.Message, want[i])
+		}
+		if !strings.Contains(d.Pos, "order_machine.go:") {
+			t.Errorf("diagnostic %d position = %q", i, d.Pos)
+		}
+	}
+}
+
+func TestCheckMissingState(t *testing.T) {
+	g := orderGraph(t)
+	src, err := GenerateGo(orderOptions, g)
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	if err := g.Load([]string{"Done,Archived,archive"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+
+	diags, err := CheckGo(writeGoPackage(t, src), g)
+	if err != nil {
+		t.Fatalf("CheckGo() error = %v", err)
+	}
+	if len(diags) != 1 || diags[0].String() != "state Archived has no registered action" {
+		t.Errorf("got diagnostics %v", diags)
+	}
+}
+
+func TestCheckNoFiles(t *testing.T) {
+	if _, err := CheckGo(t.TempDir(), orderGraph(t)); err == nil {
+		t.Error("expected error for directory without Go files")
+	}
+}
@@ -0,0 +1,150 @@
+package build
+
+import (
+	"fmt"
+	"strings"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// CppFiles is the output of the C++ backend: a header with a templated
+// setup function for c++/include/state_machine.hpp and a GoogleTest
+// skeleton that exercises it.
+type CppFiles struct {
+	// HeaderName is the file name of the header, e.g. order_machine.hpp
+	HeaderName string
+	Header     []byte
+	// TestName is the file name of the test, e.g. order_machine_test.cpp
+	TestName string
+	Test     []byte
+}
+
+// GenerateCpp generates a C++ scaffold for the graph. The header declares
+// a namespace named after the machine holding a key constant per node,
+// e.g. kProcessing, and a setup function template that adds one lambda
+// state per node, each returning the key of its first outgoing edge. The
+// test skeleton has one test per edge that drives the machine from the
+// edge's source state; tests of edges the stubs do not take are skipped
+// until the actions are written.
+func GenerateCpp(name string, g *graph.Graph) (*CppFiles, error) {
+	base := snakeName(name)
+	if base == "" {
+		return nil, fmt.Errorf("invalid machine name %q", name)
+	}
+	nodes := nodeOrder(g)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("graph has no states")
+	}
+
+	// key constants are prefixed so states named after C++ keywords stay
+	// valid, and distinct states may collide once sanitized
+	consts := make(map[string]string, len(nodes))
+	seen := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		c := "k" + exportedName(node)
+		if other, ok := seen[c]; ok {
+			return nil, fmt.Errorf("states %s and %s both map to %s", other, node, c)
+		}
+		seen[c] = node
+		consts[node] = c
+	}
+
+	ns := base + "_machine"
+	files := &CppFiles{
+		HeaderName: ns + ".hpp",
+		TestName:   ns + "_test.cpp",
+	}
+
+	var h strings.Builder
+	h.WriteString("#pragma once\n\n")
+	h.WriteString("#include <memory>\n")
+	h.WriteString("#include \"state_machine.hpp\"\n\n")
+	fmt.Fprintf(&h, "namespace %s {\n\n", ns)
+	fmt.Fprintf(&h, "// State keys for the %s machine\n", base)
+	for _, node := range nodes {
+		fmt.Fprintf(&h, "inline const sm::StateKey %s = %q;\n", consts[node], node)
+	}
+	h.WriteString("\n// setup adds every state to the machine and makes START the initial state.\n")
+	h.WriteString("template<typename Model, typename Input>\n")
+	h.WriteString("void setup(sm::StateMachine<Model, Input>& machine) {\n")
+	for _, node := range nodes {
+		edges := g.Nodes[node]
+		next := consts[node]
+		if len(edges) > 0 {
+			next = consts[edges[0].To]
+		}
+		h.WriteString("    machine.addState(std::make_shared<sm::State<Model, Input>>(\n")
+		fmt.Fprintf(&h, "        %s,\n", consts[node])
+		h.WriteString("        [](const sm::State<Model, Input>& state, Model& model, const Input& input) {\n")
+		h.WriteString("            // COMMENT\n")
+		for _, e := range edges {
+			fmt.Fprintf(&h, "            // %s\n", graph.MermaidTransition(e))
+		}
+		fmt.Fprintf(&h, "            return %s;\n", next)
+		h.WriteString("        }\n    ));\n")
+	}
+	if _, ok := g.Nodes["START"]; ok {
+		fmt.Fprintf(&h, "    machine.setInitialState(%s);\n", consts["START"])
+	}
+	fmt.Fprintf(&h, "}\n\n} // namespace %s\n", ns)
+	files.Header = []byte(h.String())
+
+	fixture := exportedName(base) + "MachineTest"
+	var t strings.Builder
+	t.WriteString("#include <gtest/gtest.h>\n")
+	fmt.Fprintf(&t, "#include \"%s\"\n\n", files.HeaderName)
+	fmt.Fprintf(&t, "// TODO: replace with the model and input types of the %s machine\n", base)
+	t.WriteString("struct TestModel {};\n")
+	t.WriteString("using TestInput = int;\n\n")
+	fmt.Fprintf(&t, "class %s : public ::testing::Test {\n", fixture)
+	t.WriteString("protected:\n")
+	fmt.Fprintf(&t, "    sm::StateMachine<TestModel, TestInput> machine{%q};\n", base)
+	t.WriteString("    TestModel model;\n\n")
+	fmt.Fprintf(&t, "    void SetUp() override { %s::setup(machine); }\n", ns)
+	t.WriteString("};\n\n")
+
+	fmt.Fprintf(&t, "TEST_F(%s, Setup) {\n", fixture)
+	fmt.Fprintf(&t, "    EXPECT_EQ(machine.getStates().size(), %du);\n", len(nodes))
+	if _, ok := g.Nodes["START"]; ok {
+		fmt.Fprintf(&t, "    EXPECT_EQ(machine.getCurrentState()->getKey(), %s::%s);\n", ns, consts["START"])
+	}
+	t.WriteString("}\n")
+
+	// gtest names may not contain underscores, and numbered duplicates
+	// must not clash with the name of another edge
+	testName := func(e graph.Edge) string {
+		return exportedName(e.From) + "To" + exportedName(e.To)
+	}
+	taken := make(map[string]bool)
+	for _, node := range nodes {
+		for _, e := range g.Nodes[node] {
+			taken[testName(e)] = true
+		}
+	}
+	used := make(map[string]bool)
+	for _, node := range nodes {
+		for _, e := range g.Nodes[node] {
+			test := testName(e)
+			for n := 2; used[test]; n++ {
+				if candidate := fmt.Sprintf("%s%d", testName(e), n); !taken[candidate] {
+					test = candidate
+				}
+			}
+			used[test] = true
+			taken[test] = true
+			fmt.Fprintf(&t, "\nTEST_F(%s, %s) {\n", fixture, test)
+			if e.To != g.Nodes[node][0].To {
+				// the stub always takes the first edge
+				fmt.Fprintf(&t, "    GTEST_SKIP() << \"TODO: implement the %s action\";\n", node)
+			}
+			fmt.Fprintf(&t, "    machine.setInitialState(%s::%s);\n", ns, consts[e.From])
+			fmt.Fprintf(&t, "    // TODO: choose the input that drives %s\n", graph.MermaidTransition(e))
+			t.WriteString("    TestInput input{};\n")
+			fmt.Fprintf(&t, "    EXPECT_EQ(machine.execute(model, input), %s::%s);\n", ns, consts[e.To])
+			t.WriteString("}\n")
+		}
+	}
+	files.Test = []byte(t.String())
+
+	return files, nil
+}
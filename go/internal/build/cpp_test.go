@@ -0,0 +1,230 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+const cppdir = "../../../c++/"
+
+func TestGenerateCpp(t *testing.T) {
+	files, err := GenerateCpp("order", orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateCpp() error = %v", err)
+	}
+	if files.HeaderName != "order_machine.hpp" || files.TestName != "order_machine_test.cpp" {
+		t.Errorf("file names = %s, %s", files.HeaderName, files.TestName)
+	}
+
+	for _, want := range []string{
+		"namespace order_machine {",
+		`inline const sm::StateKey kProcessing = "Processing";`,
+		"void setup(sm::StateMachine<Model, Input>& machine) {",
+		"            // Processing --> Idle : reject\n",
+		"            return kIdle;\n",
+		"    machine.setInitialState(kSTART);\n",
+	} {
+		if !strings.Contains(string(files.Header), want) {
+			t.Errorf("header missing %q\n%s", want, files.Header)
+		}
+	}
+	for _, want := range []string{
+		"#include \"order_machine.hpp\"",
+		"class OrderMachineTest : public ::testing::Test {",
+		"    EXPECT_EQ(machine.getStates().size(), 5u);\n",
+		"TEST_F(OrderMachineTest, ProcessingToIdle) {\n    machine.setInitialState(order_machine::kProcessing);\n",
+		"TEST_F(OrderMachineTest, ProcessingToDone) {\n    GTEST_SKIP() << \"TODO: implement the Processing action\";\n",
+		"    EXPECT_EQ(machine.execute(model, input), order_machine::kDone);\n",
+	} {
+		if !strings.Contains(string(files.Test), want) {
+			t.Errorf("test missing %q\n%s", want, files.Test)
+		}
+	}
+}
+
+func TestGenerateCppDuplicateEdges(t *testing.T) {
+	g := orderGraph(t)
+	if err := g.Load([]string{"Idle,Processing,resubmit"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	files, err := GenerateCpp("order", g)
+	if err != nil {
+		t.Fatalf("GenerateCpp() error = %v", err)
+	}
+	if !strings.Contains(string(files.Test), "TEST_F(OrderMachineTest, IdleToProcessing2) {") {
+		t.Errorf("duplicate edge test not renamed\n%s", files.Test)
+	}
+}
+
+func TestGenerateCppTestNames(t *testing.T) {
+	g := graph.NewGraph()
+	if err := g.Load([]string{"wait_state,X,go", "wait_state,X,again", "wait_state,X2,-"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	files, err := GenerateCpp("order", g)
+	if err != nil {
+		t.Fatalf("GenerateCpp() error = %v", err)
+	}
+	for _, want := range []string{
+		"TEST_F(OrderMachineTest, WaitStateToX) {",
+		"TEST_F(OrderMachineTest, WaitStateToX3) {",
+		"TEST_F(OrderMachineTest, WaitStateToX2) {",
+	} {
+		if strings.Count(string(files.Test), want) != 1 {
+			t.Errorf("test missing or repeated %q\n%s", want, files.Test)
+		}
+	}
+}
+
+func TestGenerateCppKeywords(t *testing.T) {
+	files, err := GenerateCpp("order", keywordGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateCpp() error = %v", err)
+	}
+	for _, want := range []string{
+		`inline const sm::StateKey kNew = "new";`,
+		`inline const sm::StateKey kDelete = "delete";`,
+		"            return kClass;\n",
+	} {
+		if !strings.Contains(string(files.Header), want) {
+			t.Errorf("header missing %q\n%s", want, files.Header)
+		}
+	}
+}
+
+func TestGenerateCppCollisions(t *testing.T) {
+	g := graph.NewGraph()
+	if err := g.Load([]string{"START,new,-", "new,New,-"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	_, err := GenerateCpp("order", g)
+	if err == nil || !strings.Contains(err.Error(), "both map to kNew") {
+		t.Errorf("GenerateCpp() error = %v, want collision", err)
+	}
+}
+
+// keywordGraph returns a graph whose states are named after C++ keywords
+// and after the setup function the header declares.
+func keywordGraph(t *testing.T) *graph.Graph {
+	t.Helper()
+	g := graph.NewGraph()
+	err := g.Load([]string{
+		"START,new,-",
+		"new,class,open",
+		"new,delete,drop",
+		"class,default,-",
+		"default,setup,-",
+		"setup,END,-",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	return g
+}
+
+// TestGenerateCppCompiles checks the generated header against the C++
+// library and runs the generated setup function.
+func TestGenerateCppCompiles(t *testing.T) {
+	cxx, err := exec.LookPath("g++")
+	if err != nil {
+		t.Skip("g++ not found")
+	}
+
+	for name, g := range map[string]*graph.Graph{
+		"order":    orderGraph(t),
+		"keywords": keywordGraph(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			files, err := GenerateCpp("order", g)
+			if err != nil {
+				t.Fatalf("GenerateCpp() error = %v", err)
+			}
+
+			dir := t.TempDir()
+			main := fmt.Sprintf(`#include "order_machine.hpp"
+
+int main() {
+    sm::StateMachine<int, int> machine("order");
+    order_machine::setup(machine);
+    int model = 0;
+    for (int i = 0; i < 10; i++) {
+        machine.execute(model, i);
+    }
+    return machine.getStates().size() == %d ? 0 : 1;
+}
+`, len(g.Nodes))
+			for name, src := range map[string][]byte{
+				files.HeaderName: files.Header,
+				"main.cpp":       []byte(main),
+			} {
+				if err := os.WriteFile(filepath.Join(dir, name), src, 0o644); err != nil {
+					t.Fatalf("WriteFile() error = %v", err)
+				}
+			}
+
+			bin := filepath.Join(dir, "order")
+			out, err := exec.Command(cxx, "-std=c++17", "-Wall", "-Werror",
+				"-I", cppdir+"include", "-o", bin,
+				filepath.Join(dir, "main.cpp"),
+			).CombinedOutput()
+			if err != nil {
+				t.Fatalf("compile failed: %v\n%s", err, out)
+			}
+			if out, err := exec.Command(bin).CombinedOutput(); err != nil {
+				t.Fatalf("generated machine failed: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+// TestGenerateCppTestPasses builds the generated GoogleTest skeleton and
+// runs it against the stubs. GoogleTest is looked up in the compiler's
+// default paths, or under $GTEST_ROOT if set.
+func TestGenerateCppTestPasses(t *testing.T) {
+	cxx, err := exec.LookPath("g++")
+	if err != nil {
+		t.Skip("g++ not found")
+	}
+
+	files, err := GenerateCpp("order", orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateCpp() error = %v", err)
+	}
+	dir := t.TempDir()
+	for name, src := range map[string][]byte{
+		files.HeaderName: files.Header,
+		files.TestName:   files.Test,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), src, 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+
+	bin := filepath.Join(dir, "order_test")
+	args := []string{"-std=c++17", "-I", cppdir + "include"}
+	if root := os.Getenv("GTEST_ROOT"); root != "" {
+		lib := filepath.Join(root, "lib")
+		args = append(args, "-I", filepath.Join(root, "include"), "-L", lib, "-Wl,-rpath,"+lib)
+	}
+	args = append(args, "-o", bin, filepath.Join(dir, files.TestName), "-lgtest", "-lgtest_main", "-pthread")
+	out, err := exec.Command(cxx, args...).CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(out), "gtest/gtest.h") || strings.Contains(string(out), "-lgtest") {
+			t.Skip("GoogleTest not found, set GTEST_ROOT")
+		}
+		t.Fatalf("compile failed: %v\n%s", err, out)
+	}
+	out, err = exec.Command(bin).CombinedOutput()
+	if err != nil {
+		t.Fatalf("generated test failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "[  SKIPPED ] 1 test") {
+		t.Errorf("want one skipped test\n%s", out)
+	}
+}
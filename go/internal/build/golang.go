@@ -0,0 +1,618 @@
+package build
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// GoOptions describes the Go state machine generated by GenerateGo.
+type GoOptions struct {
+	// Package is the package clause of the generated file
+	Package string
+	// Name is the machine name, used to derive identifiers
+	Name string
+	// Source is the diagram file name, recorded in the file header
+	Source string
+	// Model is the model type name, declared elsewhere in the package
+	Model string
+	// Input is the input type name, declared elsewhere in the package
+	Input string
+	// Events generates an event table from the transition descriptions,
+	// so the machine picks the next state itself and actions are only
+	// side effects. Input must implement sm.EventInput.
+	Events bool
+	// Completions makes unlabelled transitions, other than those from
+	// START, completion transitions: the machine takes them as soon as
+	// the source state's action has run, without waiting for input.
+	Completions bool
+	// Errors makes transitions labelled "error" error transitions: an
+	// error returned by the source state's action moves the machine to
+	// the target instead of failing Execute.
+	Errors bool
+}
+
+// goNames holds the identifiers GenerateGo derives for a machine.
+type goNames struct {
+	// TypeName is the exported machine name, e.g. Order
+	TypeName string
+	// Keys maps each node to its key constant, e.g. Idle -> OrderIdle
+	Keys map[string]string
+	// Actions maps each node to its action function, e.g. Idle -> orderIdleAction
+	Actions map[string]string
+	// Events maps each transition description to its event constant, e.g.
+	// submit -> OrderSubmitEvent. It is only filled in event table mode;
+	// descriptions that give no identifier are missing.
+	Events map[string]string
+	// Guards and Effects map the guard and action text of transition
+	// labels to their stub functions, e.g. isValid -> orderIsValidGuard
+	// and sendReceipt -> orderSendReceiptEffect. They are not filled in
+	// event table mode.
+	Guards  map[string]string
+	Effects map[string]string
+	// Completions maps each state with a completion transition to its
+	// target. It is only filled when completion transitions are enabled.
+	Completions map[string]string
+	// ErrorStates maps each state with an error transition to its target.
+	// It is only filled when error transitions are enabled.
+	ErrorStates map[string]string
+}
+
+// newGoNames derives the Go identifiers for a machine's nodes.
+func newGoNames(name string, g *graph.Graph) (*goNames, error) {
+	typeName := exportedName(name)
+	if typeName == "" {
+		return nil, fmt.Errorf("invalid machine name %q", name)
+	}
+	names := &goNames{
+		TypeName: typeName,
+		Keys:     make(map[string]string),
+		Actions:  make(map[string]string),
+	}
+	seen := make(map[string]string)
+	for _, node := range nodeOrder(g) {
+		key := names.key(node)
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("states %s and %s both map to %s", other, node, key)
+		}
+		seen[key] = node
+		names.Keys[node] = key
+		names.Actions[node] = names.action(node)
+	}
+	return names, nil
+}
+
+// addLabels derives the guard and transition action stubs for the
+// labelled transitions of the graph.
+func (n *goNames) addLabels(g *graph.Graph) error {
+	n.Guards = make(map[string]string)
+	n.Effects = make(map[string]string)
+	seen := make(map[string]string)
+	add := func(funcs map[string]string, text, suffix string) error {
+		if text == "" || funcs[text] != "" {
+			return nil
+		}
+		name := exportedName(text)
+		if name == "" {
+			return fmt.Errorf("%s %q gives no Go identifier", strings.ToLower(suffix), text)
+		}
+		fn := lowerFirst(n.TypeName) + name + suffix
+		if other, ok := seen[fn]; ok {
+			return fmt.Errorf("%q and %q both map to %s", other, text, fn)
+		}
+		seen[fn] = text
+		funcs[text] = fn
+		return nil
+	}
+	for _, node := range nodeOrder(g) {
+		for _, e := range g.Nodes[node] {
+			if err := add(n.Guards, e.Guard, "Guard"); err != nil {
+				return err
+			}
+			if err := add(n.Effects, e.Action, "Effect"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addEvents derives the event constants for the labelled transitions of
+// the graph and checks that no event is ambiguous.
+func (n *goNames) addEvents(g *graph.Graph) error {
+	checks := graph.Checks{Ambiguous: graph.SeverityError}
+	if problems := g.Lint(checks); len(problems) > 0 {
+		return fmt.Errorf("cannot build an event table: %s", problems[0].Message)
+	}
+	for _, node := range nodeOrder(g) {
+		for _, e := range g.Nodes[node] {
+			if e.Guard != "" || e.Action != "" {
+				return fmt.Errorf("cannot build an event table: guards and actions are not supported (%s)",
+					graph.MermaidTransition(e))
+			}
+		}
+	}
+
+	n.Events = make(map[string]string)
+	seen := make(map[string]string)
+	for _, key := range n.Keys {
+		seen[key] = "state"
+	}
+	for _, node := range nodeOrder(g) {
+		unlabelled := ""
+		for _, e := range n.edges(g, node) {
+			if !isEvent(e.Description) {
+				// Lint never reports these, but they share the empty event
+				if unlabelled != "" && unlabelled != e.To {
+					return fmt.Errorf("cannot build an event table: state %s has unlabelled transitions to %s and %s",
+						node, unlabelled, e.To)
+				}
+				unlabelled = e.To
+				continue
+			}
+			if _, ok := n.Events[e.Description]; ok {
+				continue
+			}
+			name := exportedName(e.Description)
+			if name == "" {
+				continue
+			}
+			constant := n.TypeName + name + "Event"
+			if other, ok := seen[constant]; ok {
+				return fmt.Errorf("event %q and %s both map to %s", e.Description, other, constant)
+			}
+			seen[constant] = fmt.Sprintf("%q", e.Description)
+			n.Events[e.Description] = constant
+		}
+	}
+	return nil
+}
+
+// addCompletions collects the completion transitions of the graph. A
+// state with an unlabelled transition may have no other transitions, as
+// the completion transition would always be taken first.
+func (n *goNames) addCompletions(g *graph.Graph) error {
+	n.Completions = make(map[string]string)
+	for _, node := range nodeOrder(g) {
+		if node == "START" {
+			continue
+		}
+		edges := n.edges(g, node)
+		for _, e := range edges {
+			if isEvent(e.Description) {
+				continue
+			}
+			for _, other := range edges {
+				if isEvent(other.Description) || other.To != e.To {
+					return fmt.Errorf("cannot use completion transitions: state %s has an unlabelled transition to %s and a transition %s",
+						node, e.To, graph.MermaidTransition(other))
+				}
+			}
+			n.Completions[node] = e.To
+			break
+		}
+	}
+	return nil
+}
+
+// addErrors collects the error transitions of the graph.
+func (n *goNames) addErrors(g *graph.Graph) error {
+	n.ErrorStates = make(map[string]string)
+	for _, node := range nodeOrder(g) {
+		for _, e := range g.Nodes[node] {
+			if e.Description != errorEvent {
+				continue
+			}
+			if to, ok := n.ErrorStates[node]; ok && to != e.To {
+				return fmt.Errorf("state %s has error transitions to %s and %s", node, to, e.To)
+			}
+			n.ErrorStates[node] = e.To
+		}
+	}
+	return nil
+}
+
+// edges returns the transitions of a node other than its error
+// transitions, which the generated actions never take themselves.
+func (n *goNames) edges(g *graph.Graph, node string) []graph.Edge {
+	if n.ErrorStates == nil {
+		return g.Nodes[node]
+	}
+	var edges []graph.Edge
+	for _, e := range g.Nodes[node] {
+		if e.Description != errorEvent {
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+// completionTable returns the name of the completion table variable, e.g.
+// orderCompletions.
+func (n *goNames) completionTable() string {
+	return lowerFirst(n.TypeName) + "Completions"
+}
+
+// event returns the expression for a transition's event in the event
+// table: its constant, a string literal, or "" for unlabelled transitions.
+func (n *goNames) event(desc string) string {
+	if !isEvent(desc) {
+		return `""`
+	}
+	if constant, ok := n.Events[desc]; ok {
+		return constant
+	}
+	return strconv.Quote(desc)
+}
+
+// eventTable returns the name of the event table variable, e.g. orderEvents.
+func (n *goNames) eventTable() string {
+	return lowerFirst(n.TypeName) + "Events"
+}
+
+// errorEvent is the label of error transitions.
+const errorEvent = "error"
+
+// isEvent reports whether a transition description names an event rather
+// than being the parser's placeholder for an unlabelled transition.
+func isEvent(desc string) bool {
+	return desc != "" && desc != "-"
+}
+
+// key returns the key constant name for a node, whether or not it is in the graph.
+func (n *goNames) key(node string) string {
+	return n.TypeName + exportedName(node)
+}
+
+// action returns the action function name for a node, whether or not it is in the graph.
+func (n *goNames) action(node string) string {
+	return lowerFirst(n.TypeName) + exportedName(node) + "Action"
+}
+
+// GenerateGo generates a Go state machine for the graph. Every node gets
+// a typed sm.StateKey constant and a stub action returning the constant of
+// its first outgoing edge, or its own key if it has none, so a misspelled
+// state is a compile error rather than a runtime one. The actions, guards
+// and transition actions take a context.Context, and New<Name>Machine
+// registers the states with sm.NewContextState and starts the machine in
+// START, with END as its final state.
+//
+// With opts.Events the descriptions become sm.Event constants and an
+// sm.EventTable built from the edges is installed on the machine, so it
+// runs without any hand written transition logic. The stub actions then
+// return their own key, which the machine ignores. Unlabelled transitions
+// are taken on the empty event.
+//
+// With opts.Completions the unlabelled transitions become an
+// sm.CompletionTable instead, and the actions of their source states only
+// run as side effects before the machine moves on. With opts.Errors the
+// transitions labelled "error" are installed with sm.SetErrorState.
+func GenerateGo(opts GoOptions, g *graph.Graph) ([]byte, error) {
+	names, err := newGoNames(opts.Name, g)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Errors {
+		if err := names.addErrors(g); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Events {
+		if err := names.addEvents(g); err != nil {
+			return nil, err
+		}
+	} else if err := names.addLabels(g); err != nil {
+		return nil, err
+	}
+	if opts.Completions {
+		if err := names.addCompletions(g); err != nil {
+			return nil, err
+		}
+	}
+	nodes := nodeOrder(g)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("graph has no states")
+	}
+
+	common := []pair{
+		{"MODEL", opts.Model},
+		{"INPUT", opts.Input},
+	}
+
+	var keys, states, actions []string
+	for _, node := range nodes {
+		next := node
+		edges := names.edges(g, node)
+		if len(edges) > 0 {
+			next = edges[0].To
+		}
+		var transitions []string
+		for _, e := range g.Nodes[node] {
+			transitions = append(transitions, "\t// "+graph.MermaidTransition(e)+"\n")
+		}
+
+		vals := append([]pair{
+			{"KEYCONST", names.Keys[node]},
+			{"STATEKEY", node},
+			{"ACTION", names.Actions[node]},
+			{"NEWSTATE", names.Keys[next]},
+			{"TRANSITIONS", strings.Join(transitions, "")},
+		}, common...)
+		keys = append(keys, fill(keyTemplate, vals))
+		states = append(states, fill(newStateTemplate, vals))
+		_, completes := names.Completions[node]
+		switch {
+		case completes:
+			actions = append(actions, fill(completionActionTemplate, vals))
+		case opts.Events:
+			actions = append(actions, fill(eventActionTemplate, vals))
+		case hasLabels(edges):
+			vals = append(vals, pair{"BODY", names.labelBody(node, edges, opts.Input)})
+			actions = append(actions, fill(labelActionTemplate, vals))
+		default:
+			actions = append(actions, fill(actionTemplate, vals))
+		}
+	}
+	actions = append(actions, names.labelStubs(g, common)...)
+
+	initial := ""
+	if key, ok := names.Keys["START"]; ok {
+		initial = fmt.Sprintf("\tif err := machine.SetInitialState(%s); err != nil {\n\t\treturn nil, err\n\t}\n", key)
+	}
+	if key, ok := names.Keys["END"]; ok {
+		initial += fmt.Sprintf("\tif err := machine.SetFinalStates(%s); err != nil {\n\t\treturn nil, err\n\t}\n", key)
+	}
+
+	events, setEvents := "", ""
+	if opts.Events {
+		events = goEvents(names, g, opts.Name)
+		setEvents = fmt.Sprintf("\tmachine.SetEventTable(%s)\n", names.eventTable())
+	}
+	setErrors := ""
+	for _, node := range nodes {
+		if to, ok := names.ErrorStates[node]; ok {
+			setErrors += fmt.Sprintf("\tmachine.SetErrorState(%s, %s)\n", names.Keys[node], names.Keys[to])
+		}
+	}
+	completions, setCompletions := "", ""
+	if opts.Completions {
+		completions = goCompletions(names, g, opts.Name)
+		setCompletions = fmt.Sprintf("\tmachine.SetCompletions(%s)\n", names.completionTable())
+	}
+
+	src := fill(goFileTemplate, append([]pair{
+		{"SOURCE", opts.Source},
+		{"PACKAGE", opts.Package},
+		{"NAME", opts.Name},
+		{"TYPENAME", names.TypeName},
+		{"KEYS", strings.Join(keys, "\n")},
+		{"STATES", strings.Join(states, "\n")},
+		{"INITIAL", initial},
+		{"EVENTS", events},
+		{"SETEVENTS", setEvents},
+		{"COMPLETIONS", completions},
+		{"SETCOMPLETIONS", setCompletions},
+		{"SETERRORS", setErrors},
+		{"ACTIONS", strings.Join(actions, "")},
+	}, common...))
+
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated source: %w", err)
+	}
+	return out, nil
+}
+
+// hasLabels reports whether any of the edges has a guard or an action.
+func hasLabels(edges []graph.Edge) bool {
+	for _, e := range edges {
+		if e.Guard != "" || e.Action != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// labelBody renders the body of the action for a state with labelled
+// transitions. The input's event, read through sm.EventInput, picks the
+// transitions to try; input is used directly if its type is sm.Event, and
+// a TODO per event asks for the event to be set otherwise. For the event,
+// and then for the transitions without one, guarded transitions are tried
+// in order and the first unguarded one is taken if none of their guards
+// hold. If nothing matches the machine stays where it is. A failed
+// transition action keeps the machine in the current state and returns the
+// error.
+func (n *goNames) labelBody(node string, edges []graph.Edge, input string) string {
+	var sb strings.Builder
+	take := func(e graph.Edge, indent string) {
+		if e.Action != "" {
+			fmt.Fprintf(&sb, "%sif err := %s(ctx, model, input); err != nil {\n", indent, n.Effects[e.Action])
+			fmt.Fprintf(&sb, "%s\treturn %s, err\n%s}\n", indent, n.Keys[node], indent)
+		}
+		fmt.Fprintf(&sb, "%sreturn %s, nil\n", indent, n.Keys[e.To])
+	}
+	// try renders the transitions for one event and reports whether the
+	// last of them is always taken
+	try := func(group []graph.Edge, indent string) bool {
+		var fallback *graph.Edge
+		for i, e := range group {
+			if e.Guard == "" {
+				if fallback == nil {
+					fallback = &group[i]
+				}
+				continue
+			}
+			fmt.Fprintf(&sb, "%sif %s(ctx, model, input) {\n", indent, n.Guards[e.Guard])
+			take(e, indent+"\t")
+			fmt.Fprintf(&sb, "%s}\n", indent)
+		}
+		if fallback != nil {
+			take(*fallback, indent)
+		}
+		return fallback != nil
+	}
+
+	var events []string
+	byEvent := make(map[string][]graph.Edge)
+	for _, e := range edges {
+		if _, ok := byEvent[e.Event]; !ok && e.Event != "" {
+			events = append(events, e.Event)
+		}
+		byEvent[e.Event] = append(byEvent[e.Event], e)
+	}
+
+	if len(events) > 0 {
+		subject := "input"
+		if input != "sm.Event" {
+			subject = "event"
+			sb.WriteString("\tvar event sm.Event\n")
+			sb.WriteString("\tif carrier, ok := any(input).(sm.EventInput); ok {\n\t\tevent = carrier.Event()\n\t}\n")
+			for _, event := range events {
+				fmt.Fprintf(&sb, "\t// TODO: set event to %q for %s inputs unless %s implements sm.EventInput\n",
+					event, event, input)
+			}
+		}
+		fmt.Fprintf(&sb, "\tswitch %s {\n", subject)
+		for _, event := range events {
+			fmt.Fprintf(&sb, "\tcase %s:\n", strconv.Quote(event))
+			try(byEvent[event], "\t\t")
+		}
+		sb.WriteString("\t}\n")
+	}
+	if !try(byEvent[""], "\t") {
+		fmt.Fprintf(&sb, "\treturn %s, nil\n", n.Keys[node])
+	}
+	return sb.String()
+}
+
+// labelStubs renders the guard and transition action stubs in the order
+// they are first used.
+func (n *goNames) labelStubs(g *graph.Graph, common []pair) []string {
+	var order []graph.Edge
+	for _, node := range nodeOrder(g) {
+		order = append(order, g.Nodes[node]...)
+	}
+	uses := func(match func(graph.Edge) bool) string {
+		var lines []string
+		for _, e := range order {
+			if match(e) {
+				lines = append(lines, "//   "+graph.MermaidTransition(e)+"\n")
+			}
+		}
+		return strings.Join(lines, "")
+	}
+
+	var stubs []string
+	done := make(map[string]bool)
+	for _, e := range order {
+		if fn := n.Guards[e.Guard]; fn != "" && !done[fn] {
+			done[fn] = true
+			guard := e.Guard
+			stubs = append(stubs, fill(guardTemplate, append([]pair{
+				{"FUNC", fn},
+				{"TEXT", guard},
+				{"USES", uses(func(o graph.Edge) bool { return o.Guard == guard })},
+			}, common...)))
+		}
+		if fn := n.Effects[e.Action]; fn != "" && !done[fn] {
+			done[fn] = true
+			action := e.Action
+			stubs = append(stubs, fill(effectTemplate, append([]pair{
+				{"FUNC", fn},
+				{"TEXT", action},
+				{"USES", uses(func(o graph.Edge) bool { return o.Action == action })},
+			}, common...)))
+		}
+	}
+	return stubs
+}
+
+// goEvents renders the event constants and event table of a machine.
+func goEvents(names *goNames, g *graph.Graph, name string) string {
+	var consts, rows []string
+	declared := make(map[string]bool)
+	for _, node := range nodeOrder(g) {
+		if _, ok := names.Completions[node]; ok {
+			continue
+		}
+		var entries []string
+		seen := make(map[string]bool)
+		for _, e := range names.edges(g, node) {
+			if seen[e.Description] {
+				continue
+			}
+			seen[e.Description] = true
+			entries = append(entries, fmt.Sprintf("\t\t%s: %s,\n", names.event(e.Description), names.Keys[e.To]))
+
+			constant, ok := names.Events[e.Description]
+			if ok && !declared[constant] {
+				declared[constant] = true
+				consts = append(consts, fill(eventConstTemplate, []pair{
+					{"EVENTCONST", constant},
+					{"EVENT", strconv.Quote(e.Description)},
+				}))
+			}
+		}
+		if len(entries) > 0 {
+			rows = append(rows, "\t"+names.Keys[node]+": {\n"+strings.Join(entries, "")+"\t},\n")
+		}
+	}
+
+	eventConsts := ""
+	if len(consts) > 0 {
+		eventConsts = fill(eventConstsTemplate, []pair{
+			{"NAME", name},
+			{"EVENTCONSTS", strings.Join(consts, "\n")},
+		})
+	}
+	return fill(eventsTemplate, []pair{
+		{"EVENTCONSTS", eventConsts},
+		{"TABLE", names.eventTable()},
+		{"NAME", name},
+		{"ROWS", strings.Join(rows, "")},
+	})
+}
+
+// goCompletions renders the completion table of a machine.
+func goCompletions(names *goNames, g *graph.Graph, name string) string {
+	var rows []string
+	for _, node := range nodeOrder(g) {
+		if to, ok := names.Completions[node]; ok {
+			rows = append(rows, fmt.Sprintf("\t%s: %s,\n", names.Keys[node], names.Keys[to]))
+		}
+	}
+	return fill(completionsTemplate, []pair{
+		{"TABLE", names.completionTable()},
+		{"NAME", name},
+		{"ROWS", strings.Join(rows, "")},
+	})
+}
+
+// GoFileName returns the file name for a generated machine, e.g. order_machine.go.
+func GoFileName(name string) string {
+	return snakeName(name) + "_machine.go"
+}
+
+// fill replaces each {{KEY}} placeholder in a template with its value.
+// All placeholders are replaced in one pass, so values, which may hold
+// label text from the diagram, are never scanned for placeholders.
+func fill(template string, vals []pair) string {
+	oldnew := make([]string, 0, 2*len(vals))
+	for _, p := range vals {
+		oldnew = append(oldnew, "{{"+p.key+"}}", p.val)
+	}
+	return strings.NewReplacer(oldnew...).Replace(template)
+}
+
+// lowerFirst lower cases the first letter of an identifier.
+func lowerFirst(s string) string {
+	runes := []rune(s)
+	if len(runes) > 0 {
+		runes[0] = unicode.ToLower(runes[0])
+	}
+	return string(runes)
+}
@@ -0,0 +1,531 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+var orderOptions = GoOptions{
+	Package: "order",
+	Name:    "order",
+	Source:  "order.md",
+	Model:   "Model",
+	Input:   "Input",
+}
+
+// orderTypes declares the model and input types the generated code expects.
+const orderTypes = `package order
+
+type Model struct {
+	value int
+}
+
+type Input int
+`
+
+// writeGoPackage writes the generated order machine and its types into a
+// temporary module that uses this repository's statemachine package.
+func writeGoPackage(t *testing.T, src []byte) string {
+	t.Helper()
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatalf("Abs() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	gomod := "module example.com/order\n\ngo 1.23\n\n" +
+		"require sqirvy.xyz/state-gen v0.0.0\n\n" +
+		"replace sqirvy.xyz/state-gen => " + root + "\n"
+	for name, data := range map[string]string{
+		"go.mod":           gomod,
+		"types.go":         orderTypes,
+		"order_machine.go": string(src),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	return dir
+}
+
+func TestGenerateGo(t *testing.T) {
+	src, err := GenerateGo(orderOptions, orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"package order\n",
+		"OrderProcessing sm.StateKey = \"Processing\"",
+		"func NewOrderMachine(model *Model) (*sm.StateMachine[Model, Input], error) {",
+		"sm.NewContextState(OrderIdle, orderIdleAction, nil),",
+		"if err := machine.SetInitialState(OrderSTART); err != nil {",
+		"if err := machine.SetFinalStates(OrderEND); err != nil {",
+		"func orderProcessingAction(ctx context.Context, current *sm.State[Model, Input], model *Model, input Input) (key sm.StateKey, err error) {",
+		"\t// Processing --> Done : accept\n",
+		"\treturn OrderIdle, nil\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source missing %q\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateGoErrors(t *testing.T) {
+	opts := orderOptions
+	opts.Name = "-"
+	if _, err := GenerateGo(opts, orderGraph(t)); err == nil {
+		t.Error("expected error for invalid name")
+	}
+	if _, err := GenerateGo(orderOptions, graph.NewGraph()); err == nil {
+		t.Error("expected error for empty graph")
+	}
+
+	g := graph.NewGraph()
+	g.AddEdge(&graph.Edge{From: "idle", To: "Idle", Description: "-"})
+	if _, err := GenerateGo(orderOptions, g); err == nil {
+		t.Error("expected error for colliding state names")
+	}
+}
+
+// TestGenerateGoCompiles builds the generated package with the go tool.
+func TestGenerateGoCompiles(t *testing.T) {
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	src, err := GenerateGo(orderOptions, orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	dir := writeGoPackage(t, src)
+
+	cmd := exec.Command(gotool, "vet", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet failed: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateGoEvents(t *testing.T) {
+	opts := orderOptions
+	opts.Events = true
+	src, err := GenerateGo(opts, orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"OrderSubmitEvent sm.Event = \"submit\"",
+		"var orderEvents = sm.EventTable{",
+		"\tOrderSTART: {\n\t\t\"\": OrderIdle,\n\t},",
+		"\t\tOrderAcceptEvent: OrderDone,\n",
+		"\tmachine.SetEventTable(orderEvents)\n",
+		"\treturn OrderProcessing, nil\n}",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source missing %q\n%s", want, src)
+		}
+	}
+}
+
+func TestGenerateGoEventsErrors(t *testing.T) {
+	opts := orderOptions
+	opts.Events = true
+	for name, edges := range map[string][]string{
+		"ambiguous event":        {"A,B,go", "A,C,go"},
+		"unlabelled transitions": {"A,B,-", "A,C,-"},
+		"colliding events":       {"A,B,go on", "B,C,go_on"},
+	} {
+		g := graph.NewGraph()
+		if err := g.Load(edges); err != nil {
+			t.Fatalf("Load Error: %v", err)
+		}
+		if _, err := GenerateGo(opts, g); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// eventMachineTest drives the generated order machine by events alone.
+const eventMachineTest = `package order
+
+import (
+	"errors"
+	"testing"
+
+	sm "sqirvy.xyz/state-gen/pkg/statemachine"
+)
+
+func TestEvents(t *testing.T) {
+	machine, err := NewOrderMachine(&Model{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []sm.Event{"", OrderSubmitEvent, OrderRejectEvent, OrderSubmitEvent, OrderAcceptEvent, ""} {
+		if _, err := machine.Execute(&Model{}, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := machine.GetCurrentState().Key; got != OrderEND || !machine.Done() {
+		t.Fatalf("machine ended in %v", got)
+	}
+	if _, err := machine.Execute(&Model{}, ""); !errors.Is(err, sm.ErrFinished) {
+		t.Fatalf("Execute() after END error = %v", err)
+	}
+}
+`
+
+// TestGenerateGoEventsRuns runs the generated event table machine without
+// touching the stub actions.
+func TestGenerateGoEventsRuns(t *testing.T) {
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	opts := orderOptions
+	opts.Events = true
+	opts.Input = "sm.Event"
+	src, err := GenerateGo(opts, orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	dir := writeGoPackage(t, src)
+	if err := os.WriteFile(filepath.Join(dir, "order_test.go"), []byte(eventMachineTest), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cmd := exec.Command(gotool, "test", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test failed: %v\n%s", err, out)
+	}
+}
+
+// labelGraph is the order diagram with UML style transition labels.
+func labelGraph(t *testing.T) *graph.Graph {
+	t.Helper()
+	g := graph.NewGraph()
+	err := g.Load([]string{
+		"START,Idle,-",
+		"Idle,Processing,submit [isValid] / sendReceipt",
+		"Idle,Rejected,submit [isInvalid]",
+		"Idle,Idle,tick / log",
+		"Processing,END,done / log",
+		"Rejected,END,-",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	return g
+}
+
+func TestGenerateGoLabels(t *testing.T) {
+	src, err := GenerateGo(orderOptions, labelGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"\tswitch event {\n\tcase \"submit\":\n" +
+			"\t\tif orderIsValidGuard(ctx, model, input) {\n" +
+			"\t\t\tif err := orderSendReceiptEffect(ctx, model, input); err != nil {\n" +
+			"\t\t\t\treturn OrderIdle, err\n" +
+			"\t\t\t}\n" +
+			"\t\t\treturn OrderProcessing, nil\n" +
+			"\t\t}\n",
+		"\t\tif orderIsInvalidGuard(ctx, model, input) {\n\t\t\treturn OrderRejected, nil\n\t\t}\n\tcase \"tick\":\n",
+		"\t// TODO: set event to \"submit\" for submit inputs unless Input implements sm.EventInput\n",
+		"func orderIsValidGuard(ctx context.Context, model *Model, input Input) bool {",
+		"func orderLogEffect(ctx context.Context, model *Model, input Input) error {",
+		"//\tIdle --> Idle : tick / log\n//\tProcessing --> [*] : done / log\n",
+		// states without labels keep the plain stub
+		"// Rejected --> [*]\n\treturn OrderEND, nil\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source missing %q\n%s", want, src)
+		}
+	}
+	if n := strings.Count(string(src), "func orderLogEffect("); n != 1 {
+		t.Errorf("orderLogEffect declared %d times", n)
+	}
+
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(gotool, "vet", ".")
+	cmd.Dir = writeGoPackage(t, src)
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet failed: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateGoLabelErrors(t *testing.T) {
+	for name, edges := range map[string][]string{
+		"no identifier":     {"A,B,go [?]"},
+		"colliding guards":  {"A,B,go [is valid]", "A,C,stop [isValid]"},
+		"event table guard": {"A,B,go [ok]"},
+	} {
+		g := graph.NewGraph()
+		if err := g.Load(edges); err != nil {
+			t.Fatalf("Load Error: %v", err)
+		}
+		opts := orderOptions
+		opts.Events = name == "event table guard"
+		if _, err := GenerateGo(opts, g); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestGenerateGoCompletions(t *testing.T) {
+	opts := orderOptions
+	opts.Completions = true
+	src, err := GenerateGo(opts, orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"var orderCompletions = sm.CompletionTable{\n\tOrderDone: OrderEND,\n}",
+		"\tmachine.SetCompletions(orderCompletions)\n",
+		"// orderDoneAction runs when the machine enters state Done",
+		// START keeps its ordinary action
+		"// [*] --> Idle\n\treturn OrderIdle, nil\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source missing %q\n%s", want, src)
+		}
+	}
+
+	g := graph.NewGraph()
+	if err := g.Load([]string{"A,B,-", "A,C,go"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	if _, err := GenerateGo(opts, g); err == nil {
+		t.Error("expected error for a completion transition next to another transition")
+	}
+}
+
+// completionMachineTest drives the generated order machine by events,
+// leaving Done by its completion transition.
+const completionMachineTest = `package order
+
+import (
+	"testing"
+
+	sm "sqirvy.xyz/state-gen/pkg/statemachine"
+)
+
+func TestCompletions(t *testing.T) {
+	machine, err := NewOrderMachine(&Model{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range []sm.Event{"", OrderSubmitEvent, OrderAcceptEvent} {
+		if _, err := machine.Execute(&Model{}, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := machine.GetCurrentState().Key; got != OrderEND {
+		t.Fatalf("machine ended in %v", got)
+	}
+}
+`
+
+// TestGenerateGoCompletionsRuns runs the generated machine with both an
+// event table and completion transitions.
+func TestGenerateGoCompletionsRuns(t *testing.T) {
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	opts := orderOptions
+	opts.Events = true
+	opts.Completions = true
+	opts.Input = "sm.Event"
+	src, err := GenerateGo(opts, orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	if strings.Contains(string(src), "\tOrderDone: {") {
+		t.Errorf("completion state in the event table\n%s", src)
+	}
+	dir := writeGoPackage(t, src)
+	if err := os.WriteFile(filepath.Join(dir, "order_test.go"), []byte(completionMachineTest), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cmd := exec.Command(gotool, "test", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test failed: %v\n%s", err, out)
+	}
+}
+
+// errorGraph is the order diagram with an error transition.
+func errorGraph(t *testing.T) *graph.Graph {
+	t.Helper()
+	g := graph.NewGraph()
+	err := g.Load([]string{
+		"START,Idle,-",
+		"Idle,Processing,submit",
+		"Processing,Failed,error",
+		"Processing,Done,accept",
+		"Failed,Idle,retry",
+		"Done,END,-",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	return g
+}
+
+func TestGenerateGoErrorTransitions(t *testing.T) {
+	opts := orderOptions
+	opts.Errors = true
+	src, err := GenerateGo(opts, errorGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	for _, want := range []string{
+		"\tmachine.SetErrorState(OrderProcessing, OrderFailed)\n",
+		// the stub skips the error transition
+		"// Processing --> Done : accept\n\treturn OrderDone, nil\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source missing %q\n%s", want, src)
+		}
+	}
+
+	opts.Events = true
+	src, err = GenerateGo(opts, errorGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	if strings.Contains(string(src), "OrderErrorEvent") {
+		t.Errorf("error transition in the event table\n%s", src)
+	}
+
+	g := graph.NewGraph()
+	if err := g.Load([]string{"A,B,error", "A,C,error"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	if _, err := GenerateGo(opts, g); err == nil {
+		t.Error("expected error for two error transitions to different states")
+	}
+}
+
+// dispatchMachineTest checks that the generated Idle action only tries
+// the transitions of the input's event.
+const dispatchMachineTest = `package order
+
+import (
+	"testing"
+
+	sm "sqirvy.xyz/state-gen/pkg/statemachine"
+)
+
+func TestDispatch(t *testing.T) {
+	for _, tc := range []struct {
+		value int
+		event sm.Event
+		want  sm.StateKey
+	}{
+		{1, "submit", OrderProcessing},
+		{-1, "submit", OrderRejected},
+		{0, "submit", OrderIdle},
+		{1, "cancel", OrderCancelled},
+		{1, "other", OrderIdle},
+	} {
+		model := &Model{value: tc.value}
+		machine, err := NewOrderMachine(model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := machine.Execute(model, ""); err != nil {
+			t.Fatal(err)
+		}
+		key, err := machine.Execute(model, tc.event)
+		if err != nil || key != tc.want {
+			t.Errorf("value %d, event %q: Execute() = %v, %v, want %v", tc.value, tc.event, key, err, tc.want)
+		}
+	}
+}
+`
+
+// TestGenerateGoLabelsDispatch runs a state whose labelled transitions
+// have different events.
+func TestGenerateGoLabelsDispatch(t *testing.T) {
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	g := graph.NewGraph()
+	err = g.Load([]string{
+		"START,Idle,-",
+		"Idle,Processing,submit [isValid] / sendReceipt",
+		"Idle,Rejected,submit [isInvalid]",
+		"Idle,Cancelled,cancel",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	opts := orderOptions
+	opts.Input = "sm.Event"
+	src, err := GenerateGo(opts, g)
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	edited := strings.NewReplacer(
+		"// orderIsValidGuard", "// isValid",
+		"func orderIsValidGuard(ctx context.Context, model *Model, input sm.Event) bool {\n\t// COMMENT\n\treturn true",
+		"func orderIsValidGuard(ctx context.Context, model *Model, input sm.Event) bool {\n\treturn model.value > 0",
+		"func orderIsInvalidGuard(ctx context.Context, model *Model, input sm.Event) bool {\n\t// COMMENT\n\treturn true",
+		"func orderIsInvalidGuard(ctx context.Context, model *Model, input sm.Event) bool {\n\treturn model.value < 0",
+	).Replace(string(src))
+	if strings.Count(edited, "return model.value") != 2 {
+		t.Fatalf("failed to edit the guards\n%s", src)
+	}
+	dir := writeGoPackage(t, []byte(edited))
+	if err := os.WriteFile(filepath.Join(dir, "order_test.go"), []byte(dispatchMachineTest), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	cmd := exec.Command(gotool, "test", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test failed: %v\n%s", err, out)
+	}
+}
+
+// TestGenerateGoPlaceholderText keeps placeholder text in diagram labels
+// as it is.
+func TestGenerateGoPlaceholderText(t *testing.T) {
+	g := orderGraph(t)
+	if err := g.Load([]string{"Done,Idle,{{MODEL}} {{INPUT}}"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	src, err := GenerateGo(orderOptions, g)
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	if !strings.Contains(string(src), "// Done --> Idle : {{MODEL}} {{INPUT}}\n") {
+		t.Errorf("label text was substituted\n%s", src)
+	}
+}
@@ -0,0 +1,398 @@
+package build
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// removedMarker starts the comment added to the keys and actions of states
+// that are no longer in the diagram.
+const removedMarker = "// REMOVED:"
+
+// edit replaces the bytes between start and end with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// MergeGo regenerates a Go state machine into a file previously written by
+// GenerateGo without touching hand written code. The key constants and
+// New<Name>Machine are replaced with freshly generated versions, as are the
+// event constants and event table when opts.Events is set and the
+// completion table when opts.Completions is set. Actions for new
+// states and stubs for new guards and transition actions are appended;
+// existing functions, including their bodies, are kept as they are. The
+// keys and actions of states that have been removed from the diagram are
+// kept so the file still compiles, marked with a REMOVED comment, and
+// returned so the caller can report them. Any other declarations in the
+// file are left alone. Actions written before actions took a context keep
+// their signature and are registered with sm.NewState, and guards and
+// transition actions without a context are called without one; other
+// functions of the file are never treated as generated. Imports the
+// generated code needs are added if the file lacks them.
+func MergeGo(existing []byte, opts GoOptions, g *graph.Graph) ([]byte, []string, error) {
+	names, err := newGoNames(opts.Name, g)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !opts.Events {
+		if err := names.addLabels(g); err != nil {
+			return nil, nil, err
+		}
+	}
+	fresh, err := GenerateGo(opts, g)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	freshSet := token.NewFileSet()
+	freshFile, err := parser.ParseFile(freshSet, "", fresh, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing generated source: %w", err)
+	}
+	oldSet := token.NewFileSet()
+	oldFile, err := parser.ParseFile(oldSet, "", existing, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing existing source: %w", err)
+	}
+
+	freshKeys := constDecl(freshFile, "StateKey")
+	freshFuncs := funcDecls(freshFile)
+	oldKeys := constDecl(oldFile, "StateKey")
+	oldFuncs := funcDecls(oldFile)
+	constructor := "New" + names.TypeName + "Machine"
+
+	// actions, guards and transition actions from before they took a
+	// context; other functions in the file are the user's own
+	legacy := make(map[string]bool)
+	for _, generated := range []map[string]string{names.Actions, names.Guards, names.Effects} {
+		for _, name := range generated {
+			if fn, ok := oldFuncs[name]; ok && !takesContext(fn) {
+				legacy[name] = true
+			}
+		}
+	}
+
+	// states whose key constants are in the old file but not the diagram
+	var removed []string
+	if oldKeys != nil {
+		for _, v := range keyValues(oldKeys) {
+			if _, ok := g.Nodes[v]; !ok {
+				removed = append(removed, v)
+			}
+		}
+	}
+	sort.Strings(removed)
+
+	var edits []edit
+
+	// key constants, keeping removed ones so old actions still compile
+	keys := source(freshSet, fresh, freshKeys)
+	if len(removed) > 0 {
+		var sb strings.Builder
+		for _, v := range removed {
+			fmt.Fprintf(&sb, "\t%s state %s is no longer in the diagram\n", removedMarker, v)
+			fmt.Fprintf(&sb, "\t%s sm.StateKey = %s\n", names.key(v), strconv.Quote(v))
+		}
+		keys = strings.TrimSuffix(keys, ")") + sb.String() + ")"
+	}
+	afterKeys := 0
+	if oldKeys != nil {
+		start, end := span(oldSet, oldKeys)
+		edits = append(edits, edit{start, end, keys})
+		afterKeys = end
+	} else {
+		afterKeys = declInsertPoint(oldSet, oldFile)
+		edits = append(edits, edit{afterKeys, afterKeys, "\n\n" + keys})
+	}
+
+	// event constants and tables, placed after the keys if they are new
+	for _, decls := range [][2]*ast.GenDecl{
+		{constDecl(freshFile, "Event"), constDecl(oldFile, "Event")},
+		{varDecl(freshFile, names.eventTable()), varDecl(oldFile, names.eventTable())},
+		{varDecl(freshFile, names.completionTable()), varDecl(oldFile, names.completionTable())},
+	} {
+		newDecl, oldDecl := decls[0], decls[1]
+		if newDecl == nil {
+			continue
+		}
+		text := source(freshSet, fresh, newDecl)
+		if oldDecl != nil {
+			start, end := span(oldSet, oldDecl)
+			edits = append(edits, edit{start, end, text})
+		} else {
+			edits = append(edits, edit{afterKeys, afterKeys, "\n\n" + text})
+		}
+	}
+
+	// constructor
+	ctor := source(freshSet, fresh, freshFuncs[constructor])
+	for _, node := range nodeOrder(g) {
+		if fn, ok := oldFuncs[names.Actions[node]]; ok && !takesContext(fn) {
+			ctor = strings.Replace(ctor,
+				fmt.Sprintf("sm.NewContextState(%s, %s,", names.Keys[node], names.Actions[node]),
+				fmt.Sprintf("sm.NewState(%s, %s,", names.Keys[node], names.Actions[node]), 1)
+		}
+	}
+	if old, ok := oldFuncs[constructor]; ok {
+		start, end := span(oldSet, old)
+		edits = append(edits, edit{start, end, ctor})
+	} else {
+		edits = append(edits, edit{len(existing), len(existing), "\n" + ctor + "\n"})
+	}
+
+	// actions for new states, and guard and transition action stubs for
+	// new labels, go at the end of the file
+	for _, decl := range freshFile.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || fn.Name.Name == constructor {
+			continue
+		}
+		if _, ok := oldFuncs[fn.Name.Name]; ok {
+			continue
+		}
+		text := dropContextArgs(freshSet, fresh, fn, legacy)
+		edits = append(edits, edit{len(existing), len(existing), "\n" + text + "\n"})
+	}
+
+	// flag the actions of removed states
+	for _, v := range removed {
+		fn, ok := oldFuncs[names.action(v)]
+		if !ok || (fn.Doc != nil && strings.Contains(fn.Doc.Text(), strings.TrimPrefix(removedMarker, "// "))) {
+			continue
+		}
+		start, _ := span(oldSet, fn)
+		marker := fmt.Sprintf("%s state %s is no longer in the diagram and this action is not registered.\n", removedMarker, v)
+		edits = append(edits, edit{start, start, marker})
+	}
+
+	// apply from the end so earlier offsets stay valid; insertions at the
+	// same offset keep the order they were added in
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+	out := existing
+	for i := 0; i < len(edits); {
+		// group insertions at the same point so they land in order
+		j := i
+		var text strings.Builder
+		for ; j < len(edits) && edits[j].start == edits[i].start && edits[j].end == edits[i].end; j++ {
+			text.WriteString(edits[j].text)
+		}
+		e := edits[i]
+		out = append(append(append([]byte{}, out[:e.start]...), text.String()...), out[e.end:]...)
+		i = j
+	}
+
+	for _, imp := range freshFile.Imports {
+		if out, err = addImport(out, imp); err != nil {
+			return nil, nil, err
+		}
+	}
+	formatted, err := format.Source(out)
+	if err != nil {
+		return nil, nil, fmt.Errorf("formatting merged source: %w", err)
+	}
+	return formatted, removed, nil
+}
+
+// constDecl returns the first const declaration of values of the named
+// statemachine type, such as StateKey.
+func constDecl(f *ast.File, typeName string) *ast.GenDecl {
+	for _, decl := range f.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range d.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if sel, ok := vs.Type.(*ast.SelectorExpr); ok && sel.Sel.Name == typeName {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// varDecl returns the var declaration of the named package level variable.
+func varDecl(f *ast.File, name string) *ast.GenDecl {
+	for _, decl := range f.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range d.Specs {
+			for _, id := range spec.(*ast.ValueSpec).Names {
+				if id.Name == name {
+					return d
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// takesContext reports whether a function's first parameter is a
+// context.Context.
+func takesContext(fn *ast.FuncDecl) bool {
+	params := fn.Type.Params.List
+	if len(params) == 0 {
+		return false
+	}
+	sel, ok := params[0].Type.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "context" && sel.Sel.Name == "Context"
+}
+
+// dropContextArgs returns the text of a generated declaration with the
+// ctx argument removed from its calls to the legacy functions, which do
+// not take one.
+func dropContextArgs(fset *token.FileSet, src []byte, decl *ast.FuncDecl, legacy map[string]bool) string {
+	start, end := span(fset, decl)
+	var cuts [][2]int
+	ast.Inspect(decl, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		fun, ok := call.Fun.(*ast.Ident)
+		if !ok || !legacy[fun.Name] {
+			return true
+		}
+		if arg, ok := call.Args[0].(*ast.Ident); !ok || arg.Name != "ctx" {
+			return true
+		}
+		to := call.Rparen
+		if len(call.Args) > 1 {
+			to = call.Args[1].Pos()
+		}
+		cuts = append(cuts, [2]int{fset.Position(call.Args[0].Pos()).Offset, fset.Position(to).Offset})
+		return true
+	})
+
+	var sb strings.Builder
+	at := start
+	for _, cut := range cuts {
+		sb.Write(src[at:cut[0]])
+		at = cut[1]
+	}
+	sb.Write(src[at:end])
+	return sb.String()
+}
+
+// addImport adds imp, an import of the generated source, to src if the
+// file refers to the package but does not import it.
+func addImport(src []byte, imp *ast.ImportSpec) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("parsing merged source: %w", err)
+	}
+	quoted := imp.Path.Value
+	for _, have := range f.Imports {
+		if have.Path.Value == quoted {
+			return src, nil
+		}
+	}
+	path, err := strconv.Unquote(quoted)
+	if err != nil {
+		return nil, fmt.Errorf("import %s: %w", quoted, err)
+	}
+	name := path[strings.LastIndex(path, "/")+1:]
+	if imp.Name != nil {
+		name = imp.Name.Name
+		quoted = name + " " + quoted
+	}
+	used := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == name && pkg.Obj == nil {
+				used = true
+			}
+		}
+		return !used
+	})
+	if !used {
+		return src, nil
+	}
+
+	at, text := fset.Position(f.Name.End()).Offset, "\n\nimport "+quoted
+	for _, decl := range f.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT && d.Lparen.IsValid() {
+			at, text = fset.Position(d.Lparen).Offset+1, "\n\t"+quoted
+			break
+		}
+	}
+	return append(append(append([]byte{}, src[:at]...), text...), src[at:]...), nil
+}
+
+// keyValues returns the string values declared in a const declaration.
+func keyValues(d *ast.GenDecl) []string {
+	var values []string
+	for _, spec := range d.Specs {
+		for _, v := range spec.(*ast.ValueSpec).Values {
+			if lit, ok := v.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+				if s, err := strconv.Unquote(lit.Value); err == nil {
+					values = append(values, s)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// funcDecls maps the names of a file's top level functions to their declarations.
+func funcDecls(f *ast.File) map[string]*ast.FuncDecl {
+	funcs := make(map[string]*ast.FuncDecl)
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil {
+			funcs[fn.Name.Name] = fn
+		}
+	}
+	return funcs
+}
+
+// span returns the byte offsets of a declaration including its doc comment.
+func span(fset *token.FileSet, decl ast.Decl) (int, int) {
+	start := decl.Pos()
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			start = d.Doc.Pos()
+		}
+	}
+	return fset.Position(start).Offset, fset.Position(decl.End()).Offset
+}
+
+// source returns the text of a declaration including its doc comment.
+func source(fset *token.FileSet, src []byte, decl ast.Decl) string {
+	start, end := span(fset, decl)
+	return string(src[start:end])
+}
+
+// declInsertPoint returns the offset just after the imports, or after the
+// package clause if there are none.
+func declInsertPoint(fset *token.FileSet, f *ast.File) int {
+	end := f.Name.End()
+	for _, decl := range f.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.IMPORT {
+			end = d.End()
+		}
+	}
+	return fset.Position(end).Offset
+}
@@ -0,0 +1,291 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// userCode replaces the generated Idle action body.
+const userCode = `	// COMMENT
+	// Idle --> Processing : submit
+	if model.value > 3 {
+		return OrderDone, nil
+	}
+	model.value++
+	return OrderProcessing, nil`
+
+// editedOrder generates the order machine and fills in the Idle action.
+func editedOrder(t *testing.T) []byte {
+	t.Helper()
+	src, err := GenerateGo(orderOptions, orderGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	edited := strings.Replace(string(src), `	// COMMENT
+	// Idle --> Processing : submit
+	return OrderProcessing, nil`, userCode, 1)
+	if edited == string(src) {
+		t.Fatal("failed to edit generated source")
+	}
+	return []byte(edited)
+}
+
+// changedOrder is the order diagram with Done replaced by Cancelled.
+func changedOrder(t *testing.T) *graph.Graph {
+	t.Helper()
+	g := graph.NewGraph()
+	err := g.Load([]string{
+		"START,Idle,-",
+		"Idle,Processing,submit",
+		"Processing,Idle,reject",
+		"Processing,Cancelled,cancel",
+		"Cancelled,END,-",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	return g
+}
+
+func TestMergeGoUnchanged(t *testing.T) {
+	existing := editedOrder(t)
+	merged, removed, err := MergeGo(existing, orderOptions, orderGraph(t))
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("removed = %v, want none", removed)
+	}
+	if string(merged) != string(existing) {
+		t.Errorf("merge with the same diagram changed the file\n%s", merged)
+	}
+}
+
+func TestMergeGo(t *testing.T) {
+	merged, removed, err := MergeGo(editedOrder(t), orderOptions, changedOrder(t))
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	if !reflect.DeepEqual(removed, []string{"Done"}) {
+		t.Errorf("removed = %v, want [Done]", removed)
+	}
+
+	src := string(merged)
+	for _, want := range []string{
+		userCode,
+		"OrderCancelled  sm.StateKey = \"Cancelled\"",
+		"// REMOVED: state Done is no longer in the diagram\n\tOrderDone sm.StateKey = \"Done\"",
+		"sm.NewContextState(OrderCancelled, orderCancelledAction, nil),",
+		"// REMOVED: state Done is no longer in the diagram and this action is not registered.\n// orderDoneAction is the action for state Done.",
+		"func orderCancelledAction(",
+		"\t// Cancelled --> [*]\n",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("merged source missing %q\n%s", want, src)
+		}
+	}
+	if strings.Contains(src, "sm.NewContextState(OrderDone,") {
+		t.Errorf("removed state is still registered\n%s", src)
+	}
+
+	// merging again is a no-op
+	again, _, err := MergeGo(merged, orderOptions, changedOrder(t))
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	if string(again) != src {
+		t.Errorf("second merge changed the file\n%s", again)
+	}
+
+	// the merged file still builds
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(gotool, "vet", ".")
+	cmd.Dir = writeGoPackage(t, merged)
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet failed: %v\n%s", err, out)
+	}
+}
+
+func TestMergeGoMissingDeclarations(t *testing.T) {
+	existing := []byte("package order\n\nimport (\n\tsm \"sqirvy.xyz/state-gen/pkg/statemachine\"\n)\n\nvar _ sm.StateKey\n")
+	merged, _, err := MergeGo(existing, orderOptions, orderGraph(t))
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	for _, want := range []string{
+		"var _ sm.StateKey",
+		"OrderIdle       sm.StateKey = \"Idle\"",
+		"func NewOrderMachine(",
+		"func orderIdleAction(",
+	} {
+		if !strings.Contains(string(merged), want) {
+			t.Errorf("merged source missing %q\n%s", want, merged)
+		}
+	}
+}
+
+func TestMergeGoInvalidSource(t *testing.T) {
+	if _, _, err := MergeGo([]byte("not go"), orderOptions, orderGraph(t)); err == nil {
+		t.Error("expected error for invalid existing source")
+	}
+}
+
+func TestMergeGoEvents(t *testing.T) {
+	opts := orderOptions
+	opts.Events = true
+
+	// switching an existing file to event table mode adds the table
+	merged, _, err := MergeGo(editedOrder(t), opts, orderGraph(t))
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	for _, want := range []string{
+		userCode,
+		"OrderSubmitEvent sm.Event = \"submit\"",
+		"var orderEvents = sm.EventTable{",
+		"machine.SetEventTable(orderEvents)",
+	} {
+		if !strings.Contains(string(merged), want) {
+			t.Errorf("merged source missing %q\n%s", want, merged)
+		}
+	}
+
+	// a changed diagram rewrites the table
+	merged, _, err = MergeGo(merged, opts, changedOrder(t))
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	src := string(merged)
+	if !strings.Contains(src, "OrderCancelEvent: OrderCancelled,") || strings.Contains(src, "OrderAcceptEvent") {
+		t.Errorf("event table not updated\n%s", src)
+	}
+	again, _, err := MergeGo(merged, opts, changedOrder(t))
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	if string(again) != src {
+		t.Errorf("second merge changed the file\n%s", again)
+	}
+}
+
+func TestMergeGoLabels(t *testing.T) {
+	merged, _, err := MergeGo(editedOrder(t), orderOptions, labelGraph(t))
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	src := string(merged)
+	for _, want := range []string{
+		userCode,
+		"func orderIsValidGuard(",
+		"func orderSendReceiptEffect(",
+		"func orderLogEffect(",
+		"func orderRejectedAction(",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("merged source missing %q\n%s", want, src)
+		}
+	}
+	again, _, err := MergeGo(merged, orderOptions, labelGraph(t))
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	if string(again) != src {
+		t.Errorf("second merge changed the file\n%s", again)
+	}
+}
+
+// TestMergeGoLegacyActions merges into a file generated before actions,
+// guards and transition actions took a context.
+func TestMergeGoLegacyActions(t *testing.T) {
+	src, err := GenerateGo(orderOptions, labelGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	legacy := strings.NewReplacer(
+		"\t\"context\"\n\n", "",
+		"ctx context.Context, ", "",
+		"(ctx, ", "(",
+		"sm.NewContextState(", "sm.NewState(",
+	).Replace(string(src))
+
+	g := labelGraph(t)
+	if err := g.Load([]string{"Idle,Held,hold", "Held,END,release [isValid]"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	merged, _, err := MergeGo([]byte(legacy), orderOptions, g)
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	for _, want := range []string{
+		"\t\"context\"\n",
+		"sm.NewState(OrderIdle, orderIdleAction, nil),",
+		"sm.NewContextState(OrderHeld, orderHeldAction, nil),",
+		"func orderHeldAction(ctx context.Context,",
+		"\t\tif orderIsValidGuard(model, input) {\n\t\t\treturn OrderEND, nil\n",
+	} {
+		if !strings.Contains(string(merged), want) {
+			t.Errorf("merged source missing %q\n%s", want, merged)
+		}
+	}
+
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(gotool, "vet", ".")
+	cmd.Dir = writeGoPackage(t, merged)
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet failed: %v\n%s", err, out)
+	}
+}
+
+// TestMergeGoUserFunctions merges into a file with a helper of the user's
+// that does not take a context and whose name ends like a generated guard,
+// and whose sm import was removed.
+func TestMergeGoUserFunctions(t *testing.T) {
+	src, err := GenerateGo(orderOptions, labelGraph(t))
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	existing := strings.Replace(string(src), "\tsm \"sqirvy.xyz/state-gen/pkg/statemachine\"\n", "", 1) +
+		"\n// IsValidGuard is a helper of the user's.\nfunc IsValidGuard(n int) bool { return n > 0 }\n"
+
+	g := labelGraph(t)
+	if err := g.Load([]string{"Idle,Held,hold", "Held,END,release [isValid]"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	merged, _, err := MergeGo([]byte(existing), orderOptions, g)
+	if err != nil {
+		t.Fatalf("MergeGo() error = %v", err)
+	}
+	for _, want := range []string{
+		"\tsm \"sqirvy.xyz/state-gen/pkg/statemachine\"\n",
+		"\t\tif orderIsValidGuard(ctx, model, input) {\n\t\t\treturn OrderEND, nil\n",
+		"func IsValidGuard(n int) bool { return n > 0 }",
+	} {
+		if !strings.Contains(string(merged), want) {
+			t.Errorf("merged source missing %q\n%s", want, merged)
+		}
+	}
+
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(gotool, "vet", ".")
+	cmd.Dir = writeGoPackage(t, merged)
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet failed: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,94 @@
+package build
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// PathTestOptions describes the Go test skeleton written by PathTests.
+type PathTestOptions struct {
+	// Package is the package clause of the generated file
+	Package string
+	// Name is the machine name, used to derive identifiers
+	Name string
+	// Model is the model type name of the machine under test
+	Model string
+	// Input is the input type name of the machine under test
+	Input string
+}
+
+// PathTests generates a table driven Go test with one case per path. Each
+// case executes a statemachine.StateMachine one step per edge and checks
+// the state key it lands on. The machine constructor and the mapping from
+// edge descriptions to inputs are left as TODOs for the developer, and
+// every case is skipped until the machine is constructed.
+func PathTests(opts PathTestOptions, paths []graph.Path) ([]byte, error) {
+	name := exportedName(opts.Name)
+	if name == "" {
+		return nil, fmt.Errorf("invalid machine name %q", opts.Name)
+	}
+	lower := lowerFirst(name)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "package %s\n\n", opts.Package)
+	sb.WriteString("import (\n\t\"testing\"\n\n\tsm \"sqirvy.xyz/state-gen/pkg/statemachine\"\n)\n\n")
+	fmt.Fprintf(&sb, "// %sStep is one transition along a diagram path. Event is the\n", lower)
+	sb.WriteString("// transition description from the diagram and want is the state the\n")
+	sb.WriteString("// machine should be in after executing it.\n")
+	fmt.Fprintf(&sb, "type %sStep struct {\n\tevent string\n\twant sm.StateKey\n}\n\n", lower)
+
+	fmt.Fprintf(&sb, "func Test%sPaths(t *testing.T) {\n", name)
+	fmt.Fprintf(&sb, "\ttests := []struct {\n\t\tname string\n\t\tsteps []%sStep\n\t}{\n", lower)
+	for i, p := range paths {
+		fmt.Fprintf(&sb, "\t\t{\n\t\t\tname: %q,\n", fmt.Sprintf("path %d: %s", i+1, strings.Join(p.States(), " -> ")))
+		fmt.Fprintf(&sb, "\t\t\tsteps: []%sStep{\n", lower)
+		for _, e := range p {
+			fmt.Fprintf(&sb, "\t\t\t\t{event: %q, want: %q},\n", e.Description, e.To)
+		}
+		sb.WriteString("\t\t\t},\n\t\t},\n")
+	}
+	sb.WriteString("\t}\n\n")
+
+	sb.WriteString("\tfor _, tt := range tests {\n\t\tt.Run(tt.name, func(t *testing.T) {\n")
+	sb.WriteString("\t\t\t// TODO: construct the machine under test and its model, then\n")
+	sb.WriteString("\t\t\t// remove the skip\n")
+	sb.WriteString("\t\t\tt.Skip(\"TODO: construct the machine\")\n")
+	fmt.Fprintf(&sb, "\t\t\tvar machine *sm.StateMachine[%s, %s]\n", opts.Model, opts.Input)
+	fmt.Fprintf(&sb, "\t\t\tvar model %s\n\n", opts.Model)
+	sb.WriteString("\t\t\tfor _, step := range tt.steps {\n")
+	sb.WriteString("\t\t\t\t// TODO: convert step.event into the machine's input\n")
+	fmt.Fprintf(&sb, "\t\t\t\tvar input %s\n", opts.Input)
+	sb.WriteString("\t\t\t\tkey, err := machine.Execute(&model, input)\n")
+	sb.WriteString("\t\t\t\tif err != nil {\n\t\t\t\t\tt.Fatalf(\"event %q: unexpected error: %s\", step.event, err)\n\t\t\t\t}\n")
+	sb.WriteString("\t\t\t\tif key != step.want {\n\t\t\t\t\tt.Fatalf(\"event %q: got state %v, want %v\", step.event, key, step.want)\n\t\t\t\t}\n")
+	sb.WriteString("\t\t\t}\n\t\t})\n\t}\n}\n")
+
+	return format.Source([]byte(sb.String()))
+}
+
+// exportedName converts a diagram or file name into an exported Go
+// identifier, dropping characters that are not letters or digits and
+// capitalizing the letter after each one dropped.
+func exportedName(s string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if sb.Len() == 0 && unicode.IsDigit(r) {
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,100 @@
+package build
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+func TestPathTests(t *testing.T) {
+	paths := []graph.Path{
+		{
+			{From: "START", To: "Idle", Description: "-"},
+			{From: "Idle", To: "END", Description: "done"},
+		},
+	}
+	opts := PathTestOptions{Package: "order", Name: "order-flow", Model: "XModel", Input: "XInput"}
+
+	src, err := PathTests(opts, paths)
+	if err != nil {
+		t.Fatalf("PathTests() error = %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "order_test.go", src, 0); err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+
+	for _, want := range []string{
+		"func TestOrderFlowPaths(t *testing.T)",
+		`name: "path 1: START -> Idle -> END"`,
+		`{event: "done", want: "END"}`,
+		"*sm.StateMachine[XModel, XInput]",
+		"\t\t\tt.Skip(\"TODO: construct the machine\")\n",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source missing %q\n%s", want, src)
+		}
+	}
+}
+
+// TestPathTestsRun runs the generated skeleton next to a generated
+// machine; its cases skip rather than fail until they are filled in.
+func TestPathTestsRun(t *testing.T) {
+	gotool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	g := orderGraph(t)
+	machine, err := GenerateGo(orderOptions, g)
+	if err != nil {
+		t.Fatalf("GenerateGo() error = %v", err)
+	}
+	paths, _, err := g.EdgeCoverPaths("START", "END")
+	if err != nil {
+		t.Fatalf("EdgeCoverPaths() error = %v", err)
+	}
+	opts := PathTestOptions{Package: "order", Name: "order", Model: "Model", Input: "Input"}
+	src, err := PathTests(opts, paths)
+	if err != nil {
+		t.Fatalf("PathTests() error = %v", err)
+	}
+
+	dir := writeGoPackage(t, machine)
+	if err := os.WriteFile(filepath.Join(dir, "order_paths_test.go"), src, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	cmd := exec.Command(gotool, "test", "-v", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go test failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "--- SKIP") {
+		t.Errorf("generated cases were not skipped\n%s", out)
+	}
+}
+
+func TestExportedName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"order", "Order"},
+		{"order-flow", "OrderFlow"},
+		{"my_machine.md", "MyMachineMd"},
+		{"2fa", "Fa"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := exportedName(tt.in); got != tt.want {
+			t.Errorf("exportedName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
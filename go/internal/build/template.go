@@ -1,5 +1,11 @@
 package build
 
+// pair is a template placeholder and the value that replaces it.
+type pair struct {
+	key string
+	val string
+}
+
 const stateTemplate = `
 NewState(
     "{{STATEKEY}}",
@@ -10,3 +16,134 @@ NewState(
     nil,
 )
 `
+
+// goFileTemplate is the skeleton of a generated Go state machine file.
+// {{KEYS}}, {{ACTIONS}} and {{STATES}} are filled with the rendered
+// keyTemplate, actionTemplate and newStateTemplate for every node.
+const goFileTemplate = `// Generated by parse gen from {{SOURCE}}; fill in the action bodies.
+
+package {{PACKAGE}}
+
+import (
+	"context"
+
+	sm "sqirvy.xyz/state-gen/pkg/statemachine"
+)
+
+// State keys for the {{NAME}} machine
+const (
+{{KEYS}}
+)
+{{EVENTS}}{{COMPLETIONS}}
+// New{{TYPENAME}}Machine creates the {{NAME}} state machine with every state registered.
+func New{{TYPENAME}}Machine(model *{{MODEL}}) (*sm.StateMachine[{{MODEL}}, {{INPUT}}], error) {
+	machine := sm.NewStateMachine[{{MODEL}}, {{INPUT}}](model, "{{NAME}}")
+	states := []*sm.State[{{MODEL}}, {{INPUT}}]{
+{{STATES}}
+	}
+	for _, state := range states {
+		if err := machine.AddState(state); err != nil {
+			return nil, err
+		}
+	}
+{{INITIAL}}{{SETEVENTS}}{{SETCOMPLETIONS}}{{SETERRORS}}	return machine, nil
+}
+{{ACTIONS}}
+`
+
+// keyTemplate declares the constant for a single state key.
+const keyTemplate = `	{{KEYCONST}} sm.StateKey = "{{STATEKEY}}"`
+
+// newStateTemplate registers a single state with its action.
+const newStateTemplate = `		sm.NewContextState({{KEYCONST}}, {{ACTION}}, nil),`
+
+// actionTemplate is the stub action for a single state. {{TRANSITIONS}}
+// lists the state's outgoing edges as comment lines.
+const actionTemplate = `
+// {{ACTION}} is the action for state {{STATEKEY}}.
+func {{ACTION}}(ctx context.Context, current *sm.State[{{MODEL}}, {{INPUT}}], model *{{MODEL}}, input {{INPUT}}) (key sm.StateKey, err error) {
+	// COMMENT
+{{TRANSITIONS}}	return {{NEWSTATE}}, nil
+}
+`
+
+// eventsTemplate declares the event constants and the event table of a
+// machine generated in event table mode.
+// {{EVENTCONSTS}} is the rendered eventConstsTemplate, or empty if the
+// diagram has no labelled transitions.
+const eventsTemplate = `{{EVENTCONSTS}}
+// {{TABLE}} maps each state of the {{NAME}} machine and an event to the next state.
+var {{TABLE}} = sm.EventTable{
+{{ROWS}}}
+`
+
+// eventConstsTemplate declares the constants for a machine's events.
+const eventConstsTemplate = `
+// Events of the {{NAME}} machine
+const (
+{{EVENTCONSTS}}
+)
+`
+
+// eventConstTemplate declares the constant for a single event.
+const eventConstTemplate = `	{{EVENTCONST}} sm.Event = {{EVENT}}`
+
+// eventActionTemplate is the stub action for a single state in event table
+// mode. The table picks the next state, so the action is only a side effect.
+const eventActionTemplate = `
+// {{ACTION}} runs when state {{STATEKEY}} handles an event. The event
+// table picks the next state, so the key it returns is ignored.
+func {{ACTION}}(ctx context.Context, current *sm.State[{{MODEL}}, {{INPUT}}], model *{{MODEL}}, input {{INPUT}}) (key sm.StateKey, err error) {
+	// COMMENT
+{{TRANSITIONS}}	return {{KEYCONST}}, nil
+}
+`
+
+// completionsTemplate declares the completion table of a machine.
+const completionsTemplate = `
+// {{TABLE}} maps each state of the {{NAME}} machine with a completion transition to its target.
+var {{TABLE}} = sm.CompletionTable{
+{{ROWS}}}
+`
+
+// completionActionTemplate is the stub action for a state with a
+// completion transition, which runs with the zero input as the machine
+// passes through the state.
+const completionActionTemplate = `
+// {{ACTION}} runs when the machine enters state {{STATEKEY}}, which it
+// then leaves by its completion transition, so the key it returns is ignored.
+func {{ACTION}}(ctx context.Context, current *sm.State[{{MODEL}}, {{INPUT}}], model *{{MODEL}}, input {{INPUT}}) (key sm.StateKey, err error) {
+	// COMMENT
+{{TRANSITIONS}}	return {{KEYCONST}}, nil
+}
+`
+
+// labelActionTemplate is the action for a state whose transitions have
+// guards or actions. {{BODY}} checks the guards in order and runs the
+// transition actions of the one taken.
+const labelActionTemplate = `
+// {{ACTION}} is the action for state {{STATEKEY}}.
+func {{ACTION}}(ctx context.Context, current *sm.State[{{MODEL}}, {{INPUT}}], model *{{MODEL}}, input {{INPUT}}) (key sm.StateKey, err error) {
+	// COMMENT
+{{TRANSITIONS}}{{BODY}}}
+`
+
+// guardTemplate is the stub for a transition guard. {{USES}} lists the
+// transitions it guards as comment lines.
+const guardTemplate = `
+// {{FUNC}} is the guard [{{TEXT}}] on:
+{{USES}}func {{FUNC}}(ctx context.Context, model *{{MODEL}}, input {{INPUT}}) bool {
+	// COMMENT
+	return true
+}
+`
+
+// effectTemplate is the stub for a transition action. {{USES}} lists the
+// transitions that run it as comment lines.
+const effectTemplate = `
+// {{FUNC}} is the transition action / {{TEXT}} on:
+{{USES}}func {{FUNC}}(ctx context.Context, model *{{MODEL}}, input {{INPUT}}) error {
+	// COMMENT
+	return nil
+}
+`
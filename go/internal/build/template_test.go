@@ -6,11 +6,6 @@ import (
 	"testing"
 )
 
-type pair struct {
-	key string
-	val string
-}
-
 const model = "XModel"
 const input = "XInput"
 
@@ -0,0 +1,261 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+)
+
+// successors returns the distinct destinations of a node's edges in the
+// order they were first added.
+func (g *Graph) successors(node string) []string {
+	var succ []string
+	seen := make(map[string]bool)
+	for _, e := range g.Nodes[node] {
+		if !seen[e.To] {
+			seen[e.To] = true
+			succ = append(succ, e.To)
+		}
+	}
+	return succ
+}
+
+// reachable returns the set of nodes reachable from start, including start.
+func (g *Graph) reachable(start string) map[string]bool {
+	seen := map[string]bool{start: true}
+	queue := []string{start}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, next := range g.successors(node) {
+			if !seen[next] {
+				seen[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return seen
+}
+
+// ShortestPath returns a path with the fewest edges from one node to
+// another, or an error if either node is missing or to cannot be reached.
+func (g *Graph) ShortestPath(from, to string) (Path, error) {
+	if _, ok := g.Nodes[from]; !ok {
+		return nil, fmt.Errorf("node %s does not exist", from)
+	}
+	if _, ok := g.Nodes[to]; !ok {
+		return nil, fmt.Errorf("node %s does not exist", to)
+	}
+	path, ok := g.shortestPath(from, to)
+	if !ok {
+		return nil, fmt.Errorf("node %s is not reachable from %s", to, from)
+	}
+	return path, nil
+}
+
+// StronglyConnectedComponents returns the strongly connected components
+// of the graph using Tarjan's algorithm. Each component is sorted and the
+// components are ordered by their first node.
+func (g *Graph) StronglyConnectedComponents() [][]string {
+	index := 0
+	indices := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var sccs [][]string
+
+	var connect func(node string)
+	connect = func(node string) {
+		indices[node] = index
+		lowlink[node] = index
+		index++
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range g.successors(node) {
+			if _, visited := indices[next]; !visited {
+				connect(next)
+				lowlink[node] = min(lowlink[node], lowlink[next])
+			} else if onStack[next] {
+				lowlink[node] = min(lowlink[node], indices[next])
+			}
+		}
+
+		if lowlink[node] == indices[node] {
+			var scc []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				scc = append(scc, top)
+				if top == node {
+					break
+				}
+			}
+			sort.Strings(scc)
+			sccs = append(sccs, scc)
+		}
+	}
+
+	for _, node := range g.NodeNames() {
+		if _, visited := indices[node]; !visited {
+			connect(node)
+		}
+	}
+	sort.Slice(sccs, func(i, j int) bool {
+		return sccs[i][0] < sccs[j][0]
+	})
+	return sccs
+}
+
+// hasSelfLoop reports whether node has an edge to itself.
+func (g *Graph) hasSelfLoop(node string) bool {
+	for _, e := range g.Nodes[node] {
+		if e.To == node {
+			return true
+		}
+	}
+	return false
+}
+
+// Livelocks returns the cyclic strongly connected components from which
+// end cannot be reached. A machine that enters one can loop forever but
+// never finish.
+func (g *Graph) Livelocks(end string) [][]string {
+	var livelocks [][]string
+	for _, scc := range g.StronglyConnectedComponents() {
+		if len(scc) == 1 && !g.hasSelfLoop(scc[0]) {
+			continue
+		}
+		if !g.reachable(scc[0])[end] {
+			livelocks = append(livelocks, scc)
+		}
+	}
+	return livelocks
+}
+
+// Cycles returns the elementary cycles of the graph, each starting at its
+// smallest node name and listing every node once. The number of cycles
+// can grow exponentially with the size of the graph, so at most limit
+// cycles are returned; truncated reports whether more exist. A limit of
+// zero or less means no limit.
+func (g *Graph) Cycles(limit int) (cycles [][]string, truncated bool) {
+	nodes := g.NodeNames()
+	for i, start := range nodes {
+		// only visit nodes after start so each cycle is found once
+		allowed := make(map[string]bool)
+		for _, node := range nodes[i:] {
+			allowed[node] = true
+		}
+
+		onPath := map[string]bool{start: true}
+		path := []string{start}
+		var walk func(node string) bool
+		walk = func(node string) bool {
+			for _, next := range g.successors(node) {
+				if next == start {
+					if limit > 0 && len(cycles) == limit {
+						truncated = true
+						return false
+					}
+					cycles = append(cycles, append([]string{}, path...))
+					continue
+				}
+				if !allowed[next] || onPath[next] {
+					continue
+				}
+				onPath[next] = true
+				path = append(path, next)
+				if !walk(next) {
+					return false
+				}
+				path = path[:len(path)-1]
+				onPath[next] = false
+			}
+			return true
+		}
+		if !walk(start) {
+			break
+		}
+	}
+	return cycles, truncated
+}
+
+// Dominators returns, for every node reachable from start, the sorted
+// nodes that lie on every path from start to it, including start and the
+// node itself. The dominators of an end state are the checkpoints every
+// run must pass through.
+func (g *Graph) Dominators(start string) (map[string][]string, error) {
+	if _, ok := g.Nodes[start]; !ok {
+		return nil, fmt.Errorf("node %s does not exist", start)
+	}
+
+	reach := g.reachable(start)
+	var nodes []string
+	for _, node := range g.NodeNames() {
+		if reach[node] {
+			nodes = append(nodes, node)
+		}
+	}
+	preds := make(map[string][]string)
+	for _, node := range nodes {
+		for _, next := range g.successors(node) {
+			preds[next] = append(preds[next], node)
+		}
+	}
+
+	// iterate dom(n) = {n} ∪ ⋂ dom(p) for the predecessors p of n
+	dom := make(map[string]map[string]bool)
+	for _, node := range nodes {
+		dom[node] = make(map[string]bool)
+		if node == start {
+			dom[node][start] = true
+			continue
+		}
+		for _, n := range nodes {
+			dom[node][n] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, node := range nodes {
+			if node == start {
+				continue
+			}
+			var next map[string]bool
+			for _, p := range preds[node] {
+				if next == nil {
+					next = make(map[string]bool, len(dom[p]))
+					for d := range dom[p] {
+						next[d] = true
+					}
+					continue
+				}
+				for d := range next {
+					if !dom[p][d] {
+						delete(next, d)
+					}
+				}
+			}
+			if next == nil {
+				next = make(map[string]bool)
+			}
+			next[node] = true
+			// the sets only shrink, so a change in size is a change
+			if len(next) != len(dom[node]) {
+				dom[node] = next
+				changed = true
+			}
+		}
+	}
+
+	result := make(map[string][]string, len(dom))
+	for node, set := range dom {
+		var list []string
+		for d := range set {
+			list = append(list, d)
+		}
+		sort.Strings(list)
+		result[node] = list
+	}
+	return result, nil
+}
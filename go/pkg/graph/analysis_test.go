@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+// loopGraph has a livelock (Spin <-> Wait) next to the order flow.
+func loopGraph(t *testing.T) *Graph {
+	t.Helper()
+	g := orderGraph(t)
+	err := g.Load([]string{
+		"Idle,Spin,stall",
+		"Spin,Wait,-",
+		"Wait,Spin,-",
+		"Wait,Wait,poll",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	return g
+}
+
+func TestShortestPath(t *testing.T) {
+	g := orderGraph(t)
+	p, err := g.ShortestPath("START", "END")
+	if err != nil {
+		t.Fatalf("ShortestPath() error = %v", err)
+	}
+	want := []string{"START", "Idle", "Processing", "Done", "END"}
+	if got := p.States(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ShortestPath() = %v, want %v", got, want)
+	}
+
+	if _, err := g.ShortestPath("END", "START"); err == nil {
+		t.Error("expected error for unreachable node")
+	}
+	if _, err := g.ShortestPath("START", "nope"); err == nil {
+		t.Error("expected error for missing node")
+	}
+}
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	got := loopGraph(t).StronglyConnectedComponents()
+	want := [][]string{
+		{"Done"},
+		{"END"},
+		{"Idle", "Processing"},
+		{"Orphan"},
+		{"START"},
+		{"Spin", "Wait"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StronglyConnectedComponents() = %v, want %v", got, want)
+	}
+}
+
+func TestLivelocks(t *testing.T) {
+	if got := orderGraph(t).Livelocks("END"); len(got) != 0 {
+		t.Errorf("Livelocks() = %v, want none", got)
+	}
+	want := [][]string{{"Spin", "Wait"}}
+	if got := loopGraph(t).Livelocks("END"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Livelocks() = %v, want %v", got, want)
+	}
+}
+
+func TestCycles(t *testing.T) {
+	cycles, truncated := loopGraph(t).Cycles(0)
+	want := [][]string{
+		{"Idle", "Processing"},
+		{"Spin", "Wait"},
+		{"Wait"},
+	}
+	if truncated || !reflect.DeepEqual(cycles, want) {
+		t.Errorf("Cycles() = %v, %v, want %v, false", cycles, truncated, want)
+	}
+
+	cycles, truncated = loopGraph(t).Cycles(2)
+	if !truncated || len(cycles) != 2 {
+		t.Errorf("Cycles(2) = %v, %v, want 2 cycles, true", cycles, truncated)
+	}
+}
+
+func TestDominators(t *testing.T) {
+	g := loopGraph(t)
+	// a second route to Done that skips Processing
+	g.AddEdge(&Edge{From: "Idle", To: "Done", Description: "skip"})
+
+	dom, err := g.Dominators("START")
+	if err != nil {
+		t.Fatalf("Dominators() error = %v", err)
+	}
+	tests := []struct {
+		node string
+		want []string
+	}{
+		{"START", []string{"START"}},
+		{"Processing", []string{"Idle", "Processing", "START"}},
+		{"END", []string{"Done", "END", "Idle", "START"}},
+		{"Wait", []string{"Idle", "START", "Spin", "Wait"}},
+	}
+	for _, tt := range tests {
+		if got := dom[tt.node]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Dominators()[%s] = %v, want %v", tt.node, got, tt.want)
+		}
+	}
+	if _, ok := dom["Orphan"]; ok {
+		t.Error("unreachable node has dominators")
+	}
+
+	if _, err := g.Dominators("nope"); err == nil {
+		t.Error("expected error for missing node")
+	}
+}
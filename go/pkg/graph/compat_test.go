@@ -0,0 +1,76 @@
+package graph_test
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+	parser "sqirvy.xyz/state-gen/pkg/parser"
+)
+
+// The exported API is pinned here so an incompatible change fails to
+// compile. Additions are fine; changes to these signatures need a new
+// major version of the module.
+var (
+	_ func(*parser.Diagram, ...graph.Option) (*graph.Graph, []graph.Problem, error) = graph.FromDiagram
+	_ func(graph.Checks) graph.Option                                               = graph.WithChecks
+	_ func() graph.Option                                                           = graph.Strict
+	_ func() *graph.Graph                                                           = graph.NewGraph
+	_ func(string) (*graph.Edge, error)                                             = graph.ParseEdge
+	_ func(string) (graph.Label, error)                                             = graph.ParseLabel
+	_ func(*graph.Graph, *graph.Graph) *graph.Diff                                  = graph.Compare
+	_ func(*graph.Graph, *graph.Graph) (*graph.Graph, []string, error)              = graph.Product
+	_ func(graph.Edge) string                                                       = graph.MermaidTransition
+
+	_ func(*graph.Graph, []string) error                                     = (*graph.Graph).Load
+	_ func(*graph.Graph, []string) error                                     = (*graph.Graph).LoadStrict
+	_ func(*graph.Graph, []string, graph.Checks) ([]graph.Problem, error)    = (*graph.Graph).LoadChecked
+	_ func(*graph.Graph, *graph.Edge)                                        = (*graph.Graph).AddEdge
+	_ func(*graph.Graph, string)                                             = (*graph.Graph).AddNode
+	_ func(*graph.Graph) []string                                            = (*graph.Graph).NodeNames
+	_ func(*graph.Graph, graph.Checks) []graph.Problem                       = (*graph.Graph).Lint
+	_ func(*graph.Graph) string                                              = (*graph.Graph).Mermaid
+	_ func(*graph.Graph) (*graph.Graph, []graph.Merge, error)                = (*graph.Graph).Minimize
+	_ func(*graph.Graph, string, string) (graph.Path, error)                 = (*graph.Graph).ShortestPath
+	_ func(*graph.Graph, string, string) ([]graph.Path, []graph.Edge, error) = (*graph.Graph).EdgeCoverPaths
+
+	_ = graph.Graph{Nodes: map[string][]graph.Edge{}}
+	_ = graph.Edge{From: "", To: "", Description: "", Event: "", Guard: "", Action: ""}
+	_ = graph.Checks{Duplicates: graph.SeverityIgnore, Ambiguous: graph.SeverityWarning, Labels: graph.SeverityError}
+)
+
+// TestFromDiagramMatchesLoad checks that building a graph from the
+// structured parser output gives the same graph as the original CSV path.
+func TestFromDiagramMatchesLoad(t *testing.T) {
+	for _, name := range []string{"t1.md", "t2.md", "t3.md", "s1.md", "order.md"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open("../../../test/" + name)
+			if err != nil {
+				t.Fatalf("Failed to open test file: %v", err)
+			}
+			defer f.Close()
+			csv, _ := parser.ProcessStateFile(f, false)
+			legacy := graph.NewGraph()
+			if err := legacy.Load(csv); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			d, err := parser.Parse(f)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			g, _, err := graph.FromDiagram(d)
+			if err != nil {
+				t.Fatalf("FromDiagram() error = %v", err)
+			}
+			if !reflect.DeepEqual(g.Nodes, legacy.Nodes) {
+				t.Errorf("FromDiagram() = %v\nwant %v", g.Nodes, legacy.Nodes)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package graph
+
+import (
+	parser "sqirvy.xyz/state-gen/pkg/parser"
+)
+
+// options holds the settings FromDiagram can be configured with.
+type options struct {
+	checks Checks
+}
+
+// Option configures FromDiagram.
+type Option func(*options)
+
+// WithChecks lints the graph with the given severities. Problems with
+// SeverityError reject the graph.
+func WithChecks(c Checks) Option {
+	return func(o *options) {
+		o.checks = c
+	}
+}
+
+// Strict rejects graphs with duplicate edges, ambiguous events or
+// malformed labels. It is WithChecks(StrictChecks).
+func Strict() Option {
+	return WithChecks(StrictChecks)
+}
+
+// FromDiagram builds a graph from the transitions of a parsed diagram.
+// By default no checks are run, as with Load; use WithChecks or Strict to
+// lint the graph. The problems found are returned with the graph, or
+// with the error if any of them rejected it.
+func FromDiagram(d *parser.Diagram, opts ...Option) (*Graph, []Problem, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	g := NewGraph()
+	problems, err := g.LoadChecked(d.CSV(), o.checks)
+	if err != nil {
+		return nil, problems, err
+	}
+	return g, problems, nil
+}
@@ -0,0 +1,33 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	parser "sqirvy.xyz/state-gen/pkg/parser"
+)
+
+func TestFromDiagram(t *testing.T) {
+	d, err := parser.Parse(strings.NewReader("[*] --> A\nA --> B : go\nA --> C : go\nB --> [*]\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	g, problems, err := FromDiagram(d)
+	if err != nil {
+		t.Fatalf("FromDiagram() error = %v", err)
+	}
+	if len(problems) != 0 || len(g.Nodes["A"]) != 2 {
+		t.Errorf("FromDiagram() = %v, %v", g.Nodes, problems)
+	}
+
+	_, problems, err = FromDiagram(d, WithChecks(DefaultChecks))
+	if err != nil || len(problems) != 1 {
+		t.Errorf("FromDiagram(WithChecks) = %v, %v", problems, err)
+	}
+
+	g, problems, err = FromDiagram(d, Strict())
+	if err == nil || g != nil || len(problems) != 1 {
+		t.Errorf("FromDiagram(Strict) = %v, %v, %v", g, problems, err)
+	}
+}
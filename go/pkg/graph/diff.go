@@ -0,0 +1,154 @@
+package graph
+
+import "sort"
+
+// DescriptionChange is an edge whose description differs between two graphs.
+type DescriptionChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Diff lists the structural differences between two graphs.
+type Diff struct {
+	AddedStates         []string            `json:"addedStates"`
+	RemovedStates       []string            `json:"removedStates"`
+	AddedEdges          []Edge              `json:"addedEdges"`
+	RemovedEdges        []Edge              `json:"removedEdges"`
+	ChangedDescriptions []DescriptionChange `json:"changedDescriptions"`
+	// RemovedEvents, RemovedGuards and RemovedActions are the label parts
+	// no edge of the new graph uses any more, sorted
+	RemovedEvents  []string `json:"removedEvents"`
+	RemovedGuards  []string `json:"removedGuards"`
+	RemovedActions []string `json:"removedActions"`
+}
+
+// Compare returns the differences that turn oldGraph into newGraph. Edges are
+// matched on source, destination and description. An edge that matches
+// only on source and destination is reported as a description change;
+// when a pair of states has several such edges they are paired up in the
+// order they were added.
+func Compare(oldGraph, newGraph *Graph) *Diff {
+	d := &Diff{
+		AddedStates:         []string{},
+		RemovedStates:       []string{},
+		AddedEdges:          []Edge{},
+		RemovedEdges:        []Edge{},
+		ChangedDescriptions: []DescriptionChange{},
+	}
+	d.RemovedEvents = removedLabels(oldGraph, newGraph, func(e Edge) string { return e.Event })
+	d.RemovedGuards = removedLabels(oldGraph, newGraph, func(e Edge) string { return e.Guard })
+	d.RemovedActions = removedLabels(oldGraph, newGraph, func(e Edge) string { return e.Action })
+
+	for _, node := range newGraph.NodeNames() {
+		if _, ok := oldGraph.Nodes[node]; !ok {
+			d.AddedStates = append(d.AddedStates, node)
+		}
+	}
+	for _, node := range oldGraph.NodeNames() {
+		if _, ok := newGraph.Nodes[node]; !ok {
+			d.RemovedStates = append(d.RemovedStates, node)
+		}
+	}
+
+	for _, node := range unionNames(oldGraph, newGraph) {
+		// drop the edges present in both graphs
+		oldEdges := append([]Edge{}, oldGraph.Nodes[node]...)
+		var added []Edge
+		for _, e := range newGraph.Nodes[node] {
+			if i := indexOf(oldEdges, e); i >= 0 {
+				oldEdges = append(oldEdges[:i], oldEdges[i+1:]...)
+				continue
+			}
+			added = append(added, e)
+		}
+
+		// an unmatched pair of edges between the same states is a relabel
+		removed := oldEdges
+		for _, e := range added {
+			changed := false
+			for i, o := range removed {
+				if o.From == e.From && o.To == e.To {
+					d.ChangedDescriptions = append(d.ChangedDescriptions, DescriptionChange{
+						From: e.From,
+						To:   e.To,
+						Old:  o.Description,
+						New:  e.Description,
+					})
+					removed = append(removed[:i], removed[i+1:]...)
+					changed = true
+					break
+				}
+			}
+			if !changed {
+				d.AddedEdges = append(d.AddedEdges, e)
+			}
+		}
+		d.RemovedEdges = append(d.RemovedEdges, removed...)
+	}
+	return d
+}
+
+// Empty reports whether the graphs compared were structurally identical.
+func (d *Diff) Empty() bool {
+	return len(d.AddedStates) == 0 && len(d.RemovedStates) == 0 &&
+		len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 &&
+		len(d.ChangedDescriptions) == 0
+}
+
+// Breaking reports whether the diff removes states, edges, events, guards
+// or transition actions. Code generated from the old graph may refer to
+// them, so it no longer matches the new graph: Go code has a constant for
+// every event in event table mode and a stub for every guard and action
+// otherwise. The diff cannot tell which mode was used, so any of them
+// counts.
+func (d *Diff) Breaking() bool {
+	return len(d.RemovedStates) > 0 || len(d.RemovedEdges) > 0 ||
+		len(d.RemovedEvents) > 0 || len(d.RemovedGuards) > 0 || len(d.RemovedActions) > 0
+}
+
+// removedLabels returns the sorted non-empty values of part that edges of
+// oldGraph have and no edge of newGraph has.
+func removedLabels(oldGraph, newGraph *Graph, part func(Edge) string) []string {
+	kept := make(map[string]bool)
+	for _, edges := range newGraph.Nodes {
+		for _, e := range edges {
+			kept[part(e)] = true
+		}
+	}
+	removed := []string{}
+	seen := make(map[string]bool)
+	for _, edges := range oldGraph.Nodes {
+		for _, e := range edges {
+			if v := part(e); v != "" && !kept[v] && !seen[v] {
+				seen[v] = true
+				removed = append(removed, v)
+			}
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// unionNames returns the sorted names of the nodes in either graph.
+func unionNames(a, b *Graph) []string {
+	u := NewGraph()
+	for node := range a.Nodes {
+		u.AddNode(node)
+	}
+	for node := range b.Nodes {
+		u.AddNode(node)
+	}
+	return u.NodeNames()
+}
+
+// indexOf returns the index of the first edge equal to e, or -1.
+func indexOf(edges []Edge, e Edge) int {
+	for i, o := range edges {
+		if o == e {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareIdentical(t *testing.T) {
+	d := Compare(orderGraph(t), orderGraph(t))
+	if !d.Empty() || d.Breaking() {
+		t.Errorf("Compare() of identical graphs = %+v", d)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old := orderGraph(t)
+	changed := NewGraph()
+	err := changed.Load([]string{
+		"START,Idle,-",
+		"Idle,Processing,send",
+		"Processing,Idle,reject",
+		"Processing,Cancelled,cancel",
+		"Cancelled,END,-",
+		"Orphan,Done,-",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+
+	d := Compare(old, changed)
+	want := &Diff{
+		AddedStates:   []string{"Cancelled"},
+		RemovedStates: []string{},
+		AddedEdges: []Edge{
+			{From: "Cancelled", To: "END", Description: "-"},
+			{From: "Processing", To: "Cancelled", Description: "cancel", Event: "cancel"},
+		},
+		RemovedEdges: []Edge{
+			{From: "Done", To: "END", Description: "-"},
+			{From: "Processing", To: "Done", Description: "accept", Event: "accept"},
+		},
+		ChangedDescriptions: []DescriptionChange{
+			{From: "Idle", To: "Processing", Old: "submit", New: "send"},
+		},
+		RemovedEvents:  []string{"accept", "submit"},
+		RemovedGuards:  []string{},
+		RemovedActions: []string{},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("Compare() =\n%+v\nwant\n%+v", d, want)
+	}
+	if d.Empty() || !d.Breaking() {
+		t.Errorf("Empty() = %v, Breaking() = %v", d.Empty(), d.Breaking())
+	}
+
+	// the reverse direction swaps additions and removals
+	r := Compare(changed, old)
+	if !reflect.DeepEqual(r.RemovedStates, []string{"Cancelled"}) || !reflect.DeepEqual(r.AddedEdges, want.RemovedEdges) {
+		t.Errorf("reverse Compare() = %+v", r)
+	}
+}
+
+func TestCompareAdditionsOnly(t *testing.T) {
+	changed := orderGraph(t)
+	changed.AddEdge(&Edge{From: "Done", To: "Archived", Description: "archive"})
+
+	d := Compare(orderGraph(t), changed)
+	if d.Empty() || d.Breaking() {
+		t.Errorf("Empty() = %v, Breaking() = %v, want false, false", d.Empty(), d.Breaking())
+	}
+}
+
+func TestCompareRelabelIsBreaking(t *testing.T) {
+	old := NewGraph()
+	if err := old.Load([]string{"A,B,go [ready] / log", "A,C,stop [ready]"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	changed := NewGraph()
+	if err := changed.Load([]string{"A,B,go", "A,C,stop [ready]"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+
+	d := Compare(old, changed)
+	if len(d.RemovedEdges) != 0 || len(d.ChangedDescriptions) != 1 {
+		t.Fatalf("Compare() = %+v, want one description change", d)
+	}
+	if len(d.RemovedEvents) != 0 || len(d.RemovedGuards) != 0 || !reflect.DeepEqual(d.RemovedActions, []string{"log"}) {
+		t.Errorf("removed labels = %v, %v, %v, want only the log action",
+			d.RemovedEvents, d.RemovedGuards, d.RemovedActions)
+	}
+	if !d.Breaking() {
+		t.Error("Breaking() = false for a removed transition action")
+	}
+
+	// a relabel that keeps every event, guard and action is not breaking
+	if d := Compare(changed, old); d.Breaking() {
+		t.Errorf("Breaking() = true for added labels: %+v", d)
+	}
+}
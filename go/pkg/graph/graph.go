@@ -1,11 +1,21 @@
 // Package graph creates a directed graph of states and transitions.
 // It processes CSV input from the parser to build a graph structure
 // that will be used to generate state machine states.
+//
+// A Graph maps every state name to its outgoing Edges in the order they
+// were added. The [*] pseudo state is split into START, the source of
+// the initial transitions, and END, the target of the final ones. An
+// edge's Description is the label text, "-" when the transition has
+// none, and AddEdge splits it into Event, Guard and Action following the
+// UML "event [guard] / action" convention. FromDiagram builds a graph
+// from the output of parser.Parse. The exported API follows the
+// module's semantic version.
 package graph
 
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -14,11 +24,17 @@ var edgePattern = regexp.MustCompile(`^([^,]+),([^,]+),(.*)$`)
 // Edge represents a directed edge in the graph with a description
 type Edge struct {
 	// From is the source node
-	From string
+	From string `json:"from"`
 	// To is the destination node
-	To string
+	To string `json:"to"`
 	// Description is the label or description of the edge
-	Description string
+	Description string `json:"description"`
+	// Event, Guard and Action are the parts of a description written as
+	// "event [guard] / action". AddEdge fills them in from the
+	// description; a malformed description is all event.
+	Event  string `json:"event,omitempty"`
+	Guard  string `json:"guard,omitempty"`
+	Action string `json:"action,omitempty"`
 }
 
 // ParseEdge parses a comma-separated string into an Edge
@@ -65,6 +81,17 @@ func NewGraph() *Graph {
 	}
 }
 
+// NodeNames returns the names of all nodes in sorted order, so callers
+// that walk the graph produce the same output on every run.
+func (g *Graph) NodeNames() []string {
+	names := make([]string, 0, len(g.Nodes))
+	for node := range g.Nodes {
+		names = append(names, node)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (g *Graph) AddNode(node string) {
 	_, ok := g.Nodes[node]
 	// is it already in the graph
@@ -83,8 +110,18 @@ func (g *Graph) AddEdge(edge *Edge) {
 	// ensure the from node exists
 	g.AddNode(edge.From)
 
+	// split the description into event, guard and action
+	e := *edge
+	if e.Event == "" && e.Guard == "" && e.Action == "" {
+		if l, err := ParseLabel(e.Description); err == nil {
+			e.Event, e.Guard, e.Action = l.Event, l.Guard, l.Action
+		} else {
+			e.Event = strings.TrimSpace(e.Description)
+		}
+	}
+
 	// add the edge
-	g.Nodes[edge.From] = append(g.Nodes[edge.From], *edge)
+	g.Nodes[edge.From] = append(g.Nodes[edge.From], e)
 
 	// ensure the to node exists
 	g.AddNode(edge.To)
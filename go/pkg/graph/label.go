@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Label is a transition description split by the UML convention
+// "event [guard] / action". Every part is optional.
+type Label struct {
+	// Event triggers the transition
+	Event string
+	// Guard must hold for the transition to be taken
+	Guard string
+	// Action runs when the transition is taken
+	Action string
+}
+
+// ParseLabel splits a transition description into its event, guard and
+// action. An empty description or the parser's placeholder gives an empty
+// label. A description without brackets or a slash is all event, so plain
+// text labels keep working. Unbalanced or empty brackets, text between a
+// guard and the slash, and a slash with no action are errors.
+func ParseLabel(desc string) (Label, error) {
+	s := strings.TrimSpace(desc)
+	if s == "" || s == placeholder {
+		return Label{}, nil
+	}
+
+	var l Label
+	i := strings.IndexAny(s, "[/]")
+	if i < 0 {
+		l.Event = s
+		return l, nil
+	}
+	l.Event = strings.TrimSpace(s[:i])
+	rest := s[i:]
+
+	switch rest[0] {
+	case ']':
+		return Label{}, fmt.Errorf("unexpected ] in %q", desc)
+	case '[':
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return Label{}, fmt.Errorf("unterminated guard in %q", desc)
+		}
+		l.Guard = strings.TrimSpace(rest[1:end])
+		if strings.Contains(l.Guard, "[") {
+			return Label{}, fmt.Errorf("nested [ in guard of %q", desc)
+		}
+		if l.Guard == "" {
+			return Label{}, fmt.Errorf("empty guard in %q", desc)
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+		if rest == "" {
+			return l, nil
+		}
+		if rest[0] != '/' {
+			return Label{}, fmt.Errorf("unexpected %q after guard in %q", rest, desc)
+		}
+	}
+
+	l.Action = strings.TrimSpace(rest[1:])
+	if l.Action == "" {
+		return Label{}, fmt.Errorf("missing action after / in %q", desc)
+	}
+	if strings.ContainsAny(l.Action, "[]") {
+		return Label{}, fmt.Errorf("unexpected bracket in action of %q", desc)
+	}
+	return l, nil
+}
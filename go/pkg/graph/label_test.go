@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"testing"
+)
+
+func TestParseLabel(t *testing.T) {
+	tests := []struct {
+		desc string
+		want Label
+	}{
+		{"", Label{}},
+		{"-", Label{}},
+		{"submit", Label{Event: "submit"}},
+		{"description text", Label{Event: "description text"}},
+		{"submit [isValid] / sendReceipt", Label{Event: "submit", Guard: "isValid", Action: "sendReceipt"}},
+		{"submit[isValid]/sendReceipt", Label{Event: "submit", Guard: "isValid", Action: "sendReceipt"}},
+		{"submit [count > 3]", Label{Event: "submit", Guard: "count > 3"}},
+		{"submit / log", Label{Event: "submit", Action: "log"}},
+		{"[ready]", Label{Guard: "ready"}},
+		{"/ reset", Label{Action: "reset"}},
+		{"save / write a/b", Label{Event: "save", Action: "write a/b"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLabel(tt.desc)
+		if err != nil {
+			t.Errorf("ParseLabel(%q) error = %v", tt.desc, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLabel(%q) = %+v, want %+v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestParseLabelErrors(t *testing.T) {
+	for _, desc := range []string{
+		"submit [isValid",
+		"submit ]",
+		"submit []",
+		"submit [a [b]]",
+		"submit [ok] log",
+		"submit /",
+		"submit [ok] /",
+		"submit / log [x]",
+	} {
+		if l, err := ParseLabel(desc); err == nil {
+			t.Errorf("ParseLabel(%q) = %+v, want error", desc, l)
+		}
+	}
+}
+
+func TestAddEdgeLabel(t *testing.T) {
+	g := loadGraph(t, "Idle,Processing,submit [isValid] / sendReceipt", "Idle,Failed,submit [", "Idle,END,-")
+	want := []Edge{
+		{From: "Idle", To: "Processing", Description: "submit [isValid] / sendReceipt",
+			Event: "submit", Guard: "isValid", Action: "sendReceipt"},
+		{From: "Idle", To: "Failed", Description: "submit [", Event: "submit ["},
+		{From: "Idle", To: "END", Description: "-"},
+	}
+	for i, e := range g.Nodes["Idle"] {
+		if e != want[i] {
+			t.Errorf("edge %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+
+	problems := g.Lint(Checks{Labels: SeverityError})
+	if len(problems) != 1 || problems[0].String() != `error: malformed label on Idle -> Failed: unterminated guard in "submit ["` {
+		t.Errorf("Lint() = %v", problems)
+	}
+}
@@ -0,0 +1,202 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity controls how a problem found in a graph is treated.
+type Severity int
+
+const (
+	// SeverityIgnore drops the problem.
+	SeverityIgnore Severity = iota
+	// SeverityWarning reports the problem but still accepts the graph.
+	SeverityWarning
+	// SeverityError rejects the graph.
+	SeverityError
+)
+
+// String returns the name used for the severity on the command line.
+func (s Severity) String() string {
+	switch s {
+	case SeverityIgnore:
+		return "ignore"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity converts "ignore", "warning" or "error" to a Severity.
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "ignore":
+		return SeverityIgnore, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	}
+	return SeverityIgnore, fmt.Errorf("unknown severity %q", s)
+}
+
+// Checks sets the severity of each kind of problem Lint looks for.
+type Checks struct {
+	// Duplicates covers an edge that appears more than once with the same
+	// source, destination and description.
+	Duplicates Severity
+	// Ambiguous covers edges that leave one state with the same event and
+	// guard but lead to different states, so the input alone does not
+	// decide where the machine goes. Transition actions play no part.
+	// Unlabelled edges are not events and are never ambiguous.
+	Ambiguous Severity
+	// Labels covers descriptions that do not follow the
+	// "event [guard] / action" grammar; see ParseLabel.
+	Labels Severity
+}
+
+var (
+	// DefaultChecks warns about every problem.
+	DefaultChecks = Checks{Duplicates: SeverityWarning, Ambiguous: SeverityWarning, Labels: SeverityWarning}
+	// StrictChecks rejects every problem.
+	StrictChecks = Checks{Duplicates: SeverityError, Ambiguous: SeverityError, Labels: SeverityError}
+)
+
+// Problem is an issue Lint found in a graph.
+type Problem struct {
+	Severity Severity
+	// Message describes the problem.
+	Message string
+	// Edges are the edges involved, in the order they were added.
+	Edges []Edge
+}
+
+// String formats the problem as "severity: message".
+func (p Problem) String() string {
+	return p.Severity.String() + ": " + p.Message
+}
+
+// Lint looks for duplicate edges, ambiguous events and malformed labels. Problems whose
+// severity is SeverityIgnore are not returned. States are visited in
+// sorted order and edges in the order they were added.
+func (g *Graph) Lint(c Checks) []Problem {
+	var problems []Problem
+	for _, node := range g.NodeNames() {
+		edges := g.Nodes[node]
+
+		if c.Duplicates != SeverityIgnore {
+			seen := make(map[Edge]int)
+			for _, e := range edges {
+				seen[e]++
+				if seen[e] == 2 {
+					problems = append(problems, Problem{
+						Severity: c.Duplicates,
+						Message:  fmt.Sprintf("duplicate transition %s -> %s : %s", e.From, e.To, e.Description),
+						Edges:    []Edge{e, e},
+					})
+				}
+			}
+		}
+
+		if c.Labels != SeverityIgnore {
+			for _, e := range edges {
+				if _, err := ParseLabel(e.Description); err != nil {
+					problems = append(problems, Problem{
+						Severity: c.Labels,
+						Message:  fmt.Sprintf("malformed label on %s -> %s: %v", e.From, e.To, err),
+						Edges:    []Edge{e},
+					})
+				}
+			}
+		}
+
+		if c.Ambiguous != SeverityIgnore {
+			// transitions with the same event and guard are taken on the
+			// same input, whatever their actions
+			var order []string
+			byTrigger := make(map[string][]Edge)
+			for _, e := range edges {
+				if e.Event == "" && e.Guard == "" {
+					continue
+				}
+				trigger := e.Event
+				if e.Guard != "" {
+					trigger = strings.TrimSpace(e.Event + " [" + e.Guard + "]")
+				}
+				if _, ok := byTrigger[trigger]; !ok {
+					order = append(order, trigger)
+				}
+				if indexOfTarget(byTrigger[trigger], e.To) < 0 {
+					byTrigger[trigger] = append(byTrigger[trigger], e)
+				}
+			}
+			for _, trigger := range order {
+				group := byTrigger[trigger]
+				if len(group) < 2 {
+					continue
+				}
+				targets := make([]string, len(group))
+				for i, e := range group {
+					targets[i] = e.To
+				}
+				problems = append(problems, Problem{
+					Severity: c.Ambiguous,
+					Message: fmt.Sprintf("ambiguous event %q from %s leads to %s",
+						trigger, node, strings.Join(targets, ", ")),
+					Edges: group,
+				})
+			}
+		}
+	}
+	return problems
+}
+
+// LoadChecked loads the edges like Load and then lints the graph they are
+// added to, so a new edge that duplicates or is ambiguous with an edge
+// already in the graph is found too. If any problem has SeverityError the
+// graph is left unchanged and an error listing those problems is returned.
+// Otherwise the problems found, which are all warnings, are returned for
+// the caller to report.
+func (g *Graph) LoadChecked(s []string, c Checks) ([]Problem, error) {
+	merged := NewGraph()
+	for node, edges := range g.Nodes {
+		merged.Nodes[node] = append([]Edge(nil), edges...)
+	}
+	if err := merged.Load(s); err != nil {
+		return nil, err
+	}
+
+	problems := merged.Lint(c)
+	var errs []string
+	for _, p := range problems {
+		if p.Severity == SeverityError {
+			errs = append(errs, p.Message)
+		}
+	}
+	if len(errs) > 0 {
+		return problems, fmt.Errorf("graph rejected: %s", strings.Join(errs, "; "))
+	}
+
+	g.Nodes = merged.Nodes
+	return problems, nil
+}
+
+// LoadStrict loads the edges like Load but rejects duplicate edges,
+// ambiguous events and malformed labels.
+func (g *Graph) LoadStrict(s []string) error {
+	_, err := g.LoadChecked(s, StrictChecks)
+	return err
+}
+
+// indexOfTarget returns the index of the first edge leading to node, or -1.
+func indexOfTarget(edges []Edge, node string) int {
+	for i, e := range edges {
+		if e.To == node {
+			return i
+		}
+	}
+	return -1
+}
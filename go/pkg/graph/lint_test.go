@@ -0,0 +1,133 @@
+package graph
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	g := NewGraph()
+	err := g.Load([]string{
+		"START,Idle,-",
+		"Idle,Busy,go",
+		"Idle,Busy,go",
+		"Idle,Failed,go",
+		"Idle,Idle,-",
+		"Idle,Busy,-",
+		"Busy,END,-",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+
+	problems := g.Lint(Checks{Duplicates: SeverityError, Ambiguous: SeverityWarning})
+	if len(problems) != 2 {
+		t.Fatalf("Lint() = %v, want 2 problems", problems)
+	}
+	if got := problems[0].String(); got != "error: duplicate transition Idle -> Busy : go" {
+		t.Errorf("problems[0] = %q", got)
+	}
+	if got := problems[1].String(); got != `warning: ambiguous event "go" from Idle leads to Busy, Failed` {
+		t.Errorf("problems[1] = %q", got)
+	}
+	wantEdges := []Edge{{From: "Idle", To: "Busy", Description: "go", Event: "go"}, {From: "Idle", To: "Failed", Description: "go", Event: "go"}}
+	if !reflect.DeepEqual(problems[1].Edges, wantEdges) {
+		t.Errorf("problems[1].Edges = %v, want %v", problems[1].Edges, wantEdges)
+	}
+
+	if problems := g.Lint(Checks{}); len(problems) != 0 {
+		t.Errorf("Lint() with every check ignored = %v", problems)
+	}
+}
+
+func TestLintCleanGraph(t *testing.T) {
+	if problems := orderGraph(t).Lint(StrictChecks); len(problems) != 0 {
+		t.Errorf("Lint() = %v, want none", problems)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	g := NewGraph()
+	err := g.LoadStrict([]string{"A,B,go", "A,C,go"})
+	if err == nil || !strings.Contains(err.Error(), "ambiguous event") {
+		t.Fatalf("LoadStrict() error = %v, want ambiguous event", err)
+	}
+	if len(g.Nodes) != 0 {
+		t.Errorf("LoadStrict() modified the graph after rejecting it: %v", g.NodeNames())
+	}
+
+	if err := g.LoadStrict([]string{"A,B,go", "A,C,stop"}); err != nil {
+		t.Fatalf("LoadStrict() error = %v", err)
+	}
+	if len(g.Nodes["A"]) != 2 {
+		t.Errorf("LoadStrict() loaded %v", g.Nodes)
+	}
+}
+
+func TestLoadStrictExistingEdges(t *testing.T) {
+	g := NewGraph()
+	if err := g.Load([]string{"A,B,go"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	for _, line := range []string{"A,C,go", "A,B,go"} {
+		if err := g.LoadStrict([]string{line}); err == nil {
+			t.Errorf("LoadStrict(%q) accepted a conflict with the loaded edge", line)
+		}
+	}
+	if len(g.Nodes["A"]) != 1 {
+		t.Errorf("LoadStrict() modified the graph after rejecting it: %v", g.Nodes["A"])
+	}
+}
+
+func TestLoadCheckedWarnings(t *testing.T) {
+	g := NewGraph()
+	problems, err := g.LoadChecked([]string{"A,B,go", "A,B,go"}, DefaultChecks)
+	if err != nil {
+		t.Fatalf("LoadChecked() error = %v", err)
+	}
+	if len(problems) != 1 || problems[0].Severity != SeverityWarning {
+		t.Errorf("LoadChecked() problems = %v", problems)
+	}
+	if len(g.Nodes["A"]) != 2 {
+		t.Errorf("LoadChecked() should keep both edges, got %v", g.Nodes["A"])
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []Severity{SeverityIgnore, SeverityWarning, SeverityError} {
+		got, err := ParseSeverity(s.String())
+		if err != nil || got != s {
+			t.Errorf("ParseSeverity(%q) = %v, %v", s.String(), got, err)
+		}
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("ParseSeverity(\"fatal\") should fail")
+	}
+}
+
+func TestLintAmbiguousLabels(t *testing.T) {
+	g := NewGraph()
+	err := g.Load([]string{
+		"A,X,submit / a",
+		"A,Y,submit / b",
+		"A,X,go [ok]",
+		"A,Y,go [ready]",
+		"A,Z,[ok]",
+		"A,W,[ok] / log",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	var got []string
+	for _, p := range g.Lint(Checks{Ambiguous: SeverityWarning}) {
+		got = append(got, p.Message)
+	}
+	want := []string{
+		`ambiguous event "submit" from A leads to X, Y`,
+		`ambiguous event "[ok]" from A leads to Z, W`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Lint() = %q, want %q", got, want)
+	}
+}
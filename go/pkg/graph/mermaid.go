@@ -0,0 +1,54 @@
+package graph
+
+import (
+	"strings"
+)
+
+// placeholder is the description the parser assigns to unlabeled transitions.
+const placeholder = "-"
+
+// MermaidState maps the parser's START and END names back to [*].
+func MermaidState(node string) string {
+	if node == "START" || node == "END" {
+		return "[*]"
+	}
+	return node
+}
+
+// MermaidTransition renders an edge as a canonical Mermaid transition line,
+// "From --> To : Description", omitting the description if it is empty or
+// the parser's placeholder.
+func MermaidTransition(e Edge) string {
+	line := MermaidState(e.From) + " --> " + MermaidState(e.To)
+	if e.Description != "" && e.Description != placeholder {
+		line += " : " + e.Description
+	}
+	return line
+}
+
+// Mermaid renders the graph as canonical stateDiagram-v2 text. Transitions
+// out of START come first, followed by the remaining nodes in sorted
+// order; each node's edges keep the order they were added in.
+func (g *Graph) Mermaid() string {
+	var sb strings.Builder
+	sb.WriteString("stateDiagram-v2\n")
+
+	nodes := g.NodeNames()
+	if _, ok := g.Nodes["START"]; ok {
+		ordered := []string{"START"}
+		for _, node := range nodes {
+			if node != "START" {
+				ordered = append(ordered, node)
+			}
+		}
+		nodes = ordered
+	}
+
+	for _, node := range nodes {
+		for _, e := range g.Nodes[node] {
+			sb.WriteString(MermaidTransition(e))
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
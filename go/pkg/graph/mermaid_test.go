@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	parser "sqirvy.xyz/state-gen/pkg/parser"
+)
+
+func TestMermaidTransition(t *testing.T) {
+	tests := []struct {
+		edge Edge
+		want string
+	}{
+		{Edge{From: "START", To: "A", Description: "-"}, "[*] --> A"},
+		{Edge{From: "A", To: "END", Description: "done"}, "A --> [*] : done"},
+		{Edge{From: "A", To: "B", Description: ""}, "A --> B"},
+	}
+	for _, tt := range tests {
+		if got := MermaidTransition(tt.edge); got != tt.want {
+			t.Errorf("MermaidTransition(%v) = %q, want %q", tt.edge, got, tt.want)
+		}
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	g := orderGraph(t)
+	want := `stateDiagram-v2
+[*] --> Idle
+Done --> [*]
+Idle --> Processing : submit
+Orphan --> Done
+Processing --> Idle : reject
+Processing --> Done : accept
+`
+	if got := g.Mermaid(); got != want {
+		t.Errorf("Mermaid() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestMermaidRoundTrip verifies that parsing rendered output rebuilds the same graph.
+func TestMermaidRoundTrip(t *testing.T) {
+	g := orderGraph(t)
+
+	name := filepath.Join(t.TempDir(), "order.mmd")
+	if err := os.WriteFile(name, []byte(g.Mermaid()), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	valid, err := parser.ProcessStateFile(f, false)
+	if valid == nil {
+		t.Fatalf("ProcessStateFile() error = %v", err)
+	}
+	got := NewGraph()
+	if err := got.Load(valid); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	if !reflect.DeepEqual(got.Nodes, g.Nodes) {
+		t.Errorf("round trip graph = %v, want %v", got, g)
+	}
+}
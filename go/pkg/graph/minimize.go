@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Merge records states that Minimize folded into one.
+type Merge struct {
+	// State is the state that was kept, the smallest name in the group
+	State string `json:"state"`
+	// Merged are the other states of the group, sorted
+	Merged []string `json:"merged"`
+}
+
+// Minimize returns the minimal machine equivalent to the graph, treating
+// edge descriptions as the alphabet and END as the only accepting state.
+// Two states are equivalent if every sequence of descriptions leads both
+// to END or neither; a description a state has no edge for leads nowhere,
+// as does an edge to a state that cannot reach END, so all such dead
+// states are merged. Each group of equivalent states is replaced by its
+// smallest name, and the merges made are returned sorted by that name.
+// START and END are never merged with other states. If the graph has a
+// START, states it cannot reach are dropped without being reported as
+// merges. Duplicate edges are dropped. The graph must be deterministic: a
+// state with two edges with the same description to different states is
+// an error.
+func (g *Graph) Minimize() (*Graph, []Merge, error) {
+	nodes := g.NodeNames()
+	if _, ok := g.Nodes["START"]; ok {
+		reachable := g.reachable("START")
+		kept := nodes[:0:0]
+		for _, node := range nodes {
+			if reachable[node] {
+				kept = append(kept, node)
+			}
+		}
+		nodes = kept
+	}
+	for _, node := range nodes {
+		targets := make(map[string]string)
+		for _, e := range g.Nodes[node] {
+			if to, ok := targets[e.Description]; ok && to != e.To {
+				return nil, nil, fmt.Errorf("state %s is not deterministic: %q leads to %s and %s",
+					node, e.Description, to, e.To)
+			}
+			targets[e.Description] = e.To
+		}
+	}
+
+	// Moore's algorithm: refine the partition until every block agrees on
+	// which block each description leads to. Dead states start in a block
+	// of their own and moves into it are ignored, so they stay together.
+	live := g.coreachable("END")
+	dead := make(map[string]bool)
+	block := make(map[string]int)
+	for _, node := range nodes {
+		switch {
+		case node == "START":
+			block[node] = 1
+		case node == "END":
+			block[node] = 2
+		case !live[node]:
+			dead[node] = true
+			block[node] = 3
+		default:
+			block[node] = 0
+		}
+	}
+	for count := 0; ; {
+		ids := make(map[string]int)
+		next := make(map[string]int, len(nodes))
+		for _, node := range nodes {
+			sig := g.signature(node, block, dead)
+			id, ok := ids[sig]
+			if !ok {
+				id = len(ids)
+				ids[sig] = id
+			}
+			next[node] = id
+		}
+		block = next
+		// blocks only ever split, so an unchanged count means a fixed point
+		if len(ids) == count {
+			break
+		}
+		count = len(ids)
+	}
+
+	// the smallest name in each block represents it
+	rep := make(map[int]string)
+	for _, node := range nodes {
+		if _, ok := rep[block[node]]; !ok {
+			rep[block[node]] = node
+		}
+	}
+
+	minimal := NewGraph()
+	var merges []Merge
+	index := make(map[string]int)
+	for _, node := range nodes {
+		keep := rep[block[node]]
+		if keep != node {
+			i, ok := index[keep]
+			if !ok {
+				i = len(merges)
+				index[keep] = i
+				merges = append(merges, Merge{State: keep})
+			}
+			merges[i].Merged = append(merges[i].Merged, node)
+			continue
+		}
+		minimal.AddNode(node)
+		for _, e := range g.Nodes[node] {
+			edge := Edge{From: node, To: rep[block[e.To]], Description: e.Description}
+			if indexOf(minimal.Nodes[node], edge) < 0 {
+				minimal.AddEdge(&edge)
+			}
+		}
+	}
+	return minimal, merges, nil
+}
+
+// signature describes a state by its block and the block each of its
+// descriptions leads to, so states with equal signatures stay together.
+// Moves to dead states lead nowhere and are left out.
+func (g *Graph) signature(node string, block map[string]int, dead map[string]bool) string {
+	var moves []string
+	seen := make(map[string]bool)
+	for _, e := range g.Nodes[node] {
+		if seen[e.Description] || dead[e.To] {
+			continue
+		}
+		seen[e.Description] = true
+		moves = append(moves, fmt.Sprintf("%q>%d", e.Description, block[e.To]))
+	}
+	sort.Strings(moves)
+	return fmt.Sprintf("%d|%s", block[node], strings.Join(moves, ","))
+}
+
+// coreachable returns the set of nodes end is reachable from, including
+// end, the reverse of reachable.
+func (g *Graph) coreachable(end string) map[string]bool {
+	preds := make(map[string][]string)
+	for _, node := range g.NodeNames() {
+		for _, next := range g.successors(node) {
+			preds[next] = append(preds[next], node)
+		}
+	}
+	seen := map[string]bool{end: true}
+	queue := []string{end}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, p := range preds[node] {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	return seen
+}
@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinimize(t *testing.T) {
+	g := NewGraph()
+	err := g.Load([]string{
+		"START,A,-",
+		"A,B,x",
+		"A,C,y",
+		"B,D,z",
+		"C,E,z",
+		"D,END,-",
+		"E,END,-",
+		"F,END,-",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+
+	minimal, merges, err := g.Minimize()
+	if err != nil {
+		t.Fatalf("Minimize() error = %v", err)
+	}
+	// F is unreachable from START, so it is dropped rather than merged
+	wantMerges := []Merge{
+		{State: "B", Merged: []string{"C"}},
+		{State: "D", Merged: []string{"E"}},
+	}
+	if !reflect.DeepEqual(merges, wantMerges) {
+		t.Errorf("merges = %v, want %v", merges, wantMerges)
+	}
+	if got, want := minimal.NodeNames(), []string{"A", "B", "D", "END", "START"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NodeNames() = %v, want %v", got, want)
+	}
+	wantA := []Edge{{From: "A", To: "B", Description: "x", Event: "x"}, {From: "A", To: "B", Description: "y", Event: "y"}}
+	if !reflect.DeepEqual(minimal.Nodes["A"], wantA) {
+		t.Errorf("edges of A = %v, want %v", minimal.Nodes["A"], wantA)
+	}
+
+	// minimizing again changes nothing
+	again, merges, err := minimal.Minimize()
+	if err != nil {
+		t.Fatalf("Minimize() error = %v", err)
+	}
+	if len(merges) != 0 || !reflect.DeepEqual(again.Nodes, minimal.Nodes) {
+		t.Errorf("second Minimize() merged %v", merges)
+	}
+}
+
+func TestMinimizeKeepsDistinctStates(t *testing.T) {
+	// every state of the order diagram accepts a different set of words;
+	// only the unreachable Orphan is dropped
+	minimal, merges, err := orderGraph(t).Minimize()
+	if err != nil {
+		t.Fatalf("Minimize() error = %v", err)
+	}
+	if len(merges) != 0 {
+		t.Errorf("merges = %v, want none", merges)
+	}
+	want := orderGraph(t)
+	delete(want.Nodes, "Orphan")
+	if !reflect.DeepEqual(minimal.Nodes, want.Nodes) {
+		t.Errorf("Minimize() changed the graph: %v", minimal.Nodes)
+	}
+}
+
+func TestMinimizeDeadStates(t *testing.T) {
+	// B, C and D cannot reach END, so they all lead nowhere
+	g := NewGraph()
+	err := g.Load([]string{
+		"START,A,-",
+		"A,B,x",
+		"A,END,y",
+		"B,C,a",
+		"C,D,a",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+
+	minimal, merges, err := g.Minimize()
+	if err != nil {
+		t.Fatalf("Minimize() error = %v", err)
+	}
+	if want := []Merge{{State: "B", Merged: []string{"C", "D"}}}; !reflect.DeepEqual(merges, want) {
+		t.Errorf("merges = %v, want %v", merges, want)
+	}
+	if got, want := minimal.NodeNames(), []string{"A", "B", "END", "START"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NodeNames() = %v, want %v", got, want)
+	}
+}
+
+func TestMinimizeUnreachableStates(t *testing.T) {
+	g := NewGraph()
+	err := g.Load([]string{
+		"START,A,-",
+		"A,END,go",
+		"X,A,-",
+		"Y,END,go",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+
+	minimal, merges, err := g.Minimize()
+	if err != nil {
+		t.Fatalf("Minimize() error = %v", err)
+	}
+	if len(merges) != 0 {
+		t.Errorf("merges = %v, want none", merges)
+	}
+	if got, want := minimal.NodeNames(), []string{"A", "END", "START"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NodeNames() = %v, want %v", got, want)
+	}
+}
+
+func TestMinimizeNondeterministic(t *testing.T) {
+	g := NewGraph()
+	if err := g.Load([]string{"A,B,go", "A,C,go"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	if _, _, err := g.Minimize(); err == nil {
+		t.Error("expected error for a nondeterministic graph")
+	}
+}
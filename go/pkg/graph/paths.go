@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"fmt"
+)
+
+// Path is a sequence of edges where each edge starts at the previous edge's destination.
+type Path []Edge
+
+// States returns the states visited along the path, including the first
+// edge's source. An empty path visits no states.
+func (p Path) States() []string {
+	if len(p) == 0 {
+		return nil
+	}
+	states := []string{p[0].From}
+	for _, e := range p {
+		states = append(states, e.To)
+	}
+	return states
+}
+
+// shortestPath returns the path with the fewest edges from one node to
+// another using a breadth first search. Edges are explored in node
+// insertion order so ties resolve the same way every time. It returns
+// false if to is not reachable from from.
+func (g *Graph) shortestPath(from, to string) (Path, bool) {
+	if from == to {
+		return Path{}, true
+	}
+
+	prev := map[string]Edge{}
+	visited := map[string]bool{from: true}
+	queue := []string{from}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, e := range g.Nodes[node] {
+			if visited[e.To] {
+				continue
+			}
+			visited[e.To] = true
+			prev[e.To] = e
+			if e.To == to {
+				var path Path
+				for n := to; n != from; n = prev[n].From {
+					path = append(Path{prev[n]}, path...)
+				}
+				return path, true
+			}
+			queue = append(queue, e.To)
+		}
+	}
+	return nil, false
+}
+
+// EdgeCoverPaths returns paths from start to end that together traverse
+// every edge lying on some start to end path at least once. Each path is
+// built around an edge not yet covered: the shortest route to its source,
+// the edge itself, then the shortest route from its destination to end.
+// Edges that cannot appear on any start to end path are returned as
+// uncovered.
+func (g *Graph) EdgeCoverPaths(start, end string) (paths []Path, uncovered []Edge, err error) {
+	if _, ok := g.Nodes[start]; !ok {
+		return nil, nil, fmt.Errorf("node %s does not exist", start)
+	}
+	if _, ok := g.Nodes[end]; !ok {
+		return nil, nil, fmt.Errorf("node %s does not exist", end)
+	}
+
+	covered := map[Edge]bool{}
+	for _, node := range g.NodeNames() {
+		for _, e := range g.Nodes[node] {
+			if covered[e] {
+				continue
+			}
+			head, ok := g.shortestPath(start, e.From)
+			if !ok {
+				uncovered = append(uncovered, e)
+				continue
+			}
+			tail, ok := g.shortestPath(e.To, end)
+			if !ok {
+				uncovered = append(uncovered, e)
+				continue
+			}
+
+			path := append(append(head, e), tail...)
+			for _, pe := range path {
+				covered[pe] = true
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths, uncovered, nil
+}
+
+// SimplePaths returns every path from start to end that visits no node
+// more than once and has at most maxEdges edges. A maxEdges of zero or
+// less means no bound beyond the number of nodes.
+func (g *Graph) SimplePaths(start, end string, maxEdges int) ([]Path, error) {
+	if _, ok := g.Nodes[start]; !ok {
+		return nil, fmt.Errorf("node %s does not exist", start)
+	}
+	if _, ok := g.Nodes[end]; !ok {
+		return nil, fmt.Errorf("node %s does not exist", end)
+	}
+	if maxEdges <= 0 {
+		maxEdges = len(g.Nodes)
+	}
+
+	var paths []Path
+	visited := map[string]bool{start: true}
+	var walk func(node string, path Path)
+	walk = func(node string, path Path) {
+		if node == end {
+			paths = append(paths, append(Path{}, path...))
+			return
+		}
+		if len(path) == maxEdges {
+			return
+		}
+		for _, e := range g.Nodes[node] {
+			if visited[e.To] {
+				continue
+			}
+			visited[e.To] = true
+			walk(e.To, append(path, e))
+			visited[e.To] = false
+		}
+	}
+	walk(start, Path{})
+	return paths, nil
+}
@@ -0,0 +1,99 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+// orderGraph is a small diagram with a loop and an unreachable state.
+func orderGraph(t *testing.T) *Graph {
+	t.Helper()
+	g := NewGraph()
+	err := g.Load([]string{
+		"START,Idle,-",
+		"Idle,Processing,submit",
+		"Processing,Idle,reject",
+		"Processing,Done,accept",
+		"Done,END,-",
+		"Orphan,Done,-",
+	})
+	if err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	return g
+}
+
+func TestPathStates(t *testing.T) {
+	p := Path{{From: "a", To: "b"}, {From: "b", To: "c"}}
+	if got, want := p.States(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("States() = %v, want %v", got, want)
+	}
+	if got := (Path{}).States(); got != nil {
+		t.Errorf("States() of empty path = %v, want nil", got)
+	}
+}
+
+func TestEdgeCoverPaths(t *testing.T) {
+	g := orderGraph(t)
+	paths, uncovered, err := g.EdgeCoverPaths("START", "END")
+	if err != nil {
+		t.Fatalf("EdgeCoverPaths() error = %v", err)
+	}
+
+	covered := map[Edge]bool{}
+	for _, p := range paths {
+		states := p.States()
+		if states[0] != "START" || states[len(states)-1] != "END" {
+			t.Errorf("path %v does not run from START to END", states)
+		}
+		for _, e := range p {
+			covered[e] = true
+		}
+	}
+	for _, node := range g.NodeNames() {
+		for _, e := range g.Nodes[node] {
+			if e.From == "Orphan" {
+				continue
+			}
+			if !covered[e] {
+				t.Errorf("edge %v not covered", e)
+			}
+		}
+	}
+
+	want := []Edge{{From: "Orphan", To: "Done", Description: "-"}}
+	if !reflect.DeepEqual(uncovered, want) {
+		t.Errorf("uncovered = %v, want %v", uncovered, want)
+	}
+}
+
+func TestEdgeCoverPathsMissingNode(t *testing.T) {
+	g := orderGraph(t)
+	if _, _, err := g.EdgeCoverPaths("START", "nope"); err == nil {
+		t.Error("expected error for missing end node")
+	}
+}
+
+func TestSimplePaths(t *testing.T) {
+	g := orderGraph(t)
+	paths, err := g.SimplePaths("START", "END", 0)
+	if err != nil {
+		t.Fatalf("SimplePaths() error = %v", err)
+	}
+	if len(paths) != 1 {
+		t.Fatalf("got %d paths, want 1", len(paths))
+	}
+	want := []string{"START", "Idle", "Processing", "Done", "END"}
+	if got := paths[0].States(); !reflect.DeepEqual(got, want) {
+		t.Errorf("States() = %v, want %v", got, want)
+	}
+
+	// bound shorter than the only path
+	paths, err = g.SimplePaths("START", "END", 3)
+	if err != nil {
+		t.Fatalf("SimplePaths() error = %v", err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("got %d paths, want 0", len(paths))
+	}
+}
@@ -0,0 +1,118 @@
+package graph
+
+import (
+	"fmt"
+)
+
+// ProductState names the product state that pairs state a of the first
+// graph with state b of the second. The pair of START states is START and
+// the pair of END states is END, so the product is itself a diagram.
+func ProductState(a, b string) string {
+	if a == b && (a == "START" || a == "END") {
+		return a
+	}
+	return a + "_" + b
+}
+
+// Product returns the synchronous product of two graphs. Descriptions that
+// appear in both graphs are shared events: both machines must take them
+// together. Any other description, including the unlabelled placeholder,
+// is local and is taken by one machine while the other stays where it is.
+// Only the product states reachable from the pair of START states are
+// included. The deadlocks are the reachable product states, other than
+// END, with no way out; they are returned sorted. It is an error if either
+// graph has no START state or two pairs of states get the same name.
+func Product(a, b *Graph) (*Graph, []string, error) {
+	for _, g := range []*Graph{a, b} {
+		if _, ok := g.Nodes["START"]; !ok {
+			return nil, nil, fmt.Errorf("node START does not exist")
+		}
+	}
+	shared := make(map[string]bool)
+	labels := a.descriptions()
+	for desc := range b.descriptions() {
+		if labels[desc] && desc != placeholder {
+			shared[desc] = true
+		}
+	}
+
+	p := NewGraph()
+	pairs := make(map[string][2]string)
+	var queue [][2]string
+	visit := func(pair [2]string) (string, error) {
+		name := ProductState(pair[0], pair[1])
+		if other, ok := pairs[name]; ok {
+			if other != pair {
+				return "", fmt.Errorf("states (%s, %s) and (%s, %s) are both named %s",
+					other[0], other[1], pair[0], pair[1], name)
+			}
+			return name, nil
+		}
+		pairs[name] = pair
+		p.AddNode(name)
+		queue = append(queue, pair)
+		return name, nil
+	}
+	move := func(from string, to [2]string, desc string) error {
+		name, err := visit(to)
+		if err != nil {
+			return err
+		}
+		edge := Edge{From: from, To: name, Description: desc}
+		if indexOf(p.Nodes[from], edge) < 0 {
+			p.AddEdge(&edge)
+		}
+		return nil
+	}
+
+	if _, err := visit([2]string{"START", "START"}); err != nil {
+		return nil, nil, err
+	}
+	for len(queue) > 0 {
+		pair := queue[0]
+		queue = queue[1:]
+		from := ProductState(pair[0], pair[1])
+
+		for _, ea := range a.Nodes[pair[0]] {
+			if !shared[ea.Description] {
+				if err := move(from, [2]string{ea.To, pair[1]}, ea.Description); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			for _, eb := range b.Nodes[pair[1]] {
+				if eb.Description == ea.Description {
+					if err := move(from, [2]string{ea.To, eb.To}, ea.Description); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+		}
+		for _, eb := range b.Nodes[pair[1]] {
+			if !shared[eb.Description] {
+				if err := move(from, [2]string{pair[0], eb.To}, eb.Description); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+
+	var deadlocks []string
+	for _, node := range p.NodeNames() {
+		if node != "END" && len(p.Nodes[node]) == 0 {
+			deadlocks = append(deadlocks, node)
+		}
+	}
+	return p, deadlocks, nil
+}
+
+// descriptions returns the set of edge descriptions used in the graph.
+func (g *Graph) descriptions() map[string]bool {
+	set := make(map[string]bool)
+	for _, edges := range g.Nodes {
+		for _, e := range edges {
+			set[e.Description] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+)
+
+// loadGraph loads edges into a new graph.
+func loadGraph(t *testing.T, edges ...string) *Graph {
+	t.Helper()
+	g := NewGraph()
+	if err := g.Load(edges); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	return g
+}
+
+func TestProduct(t *testing.T) {
+	client := loadGraph(t,
+		"START,Idle,-",
+		"Idle,Waiting,request",
+		"Waiting,Idle,response",
+		"Idle,END,quit",
+	)
+	server := loadGraph(t,
+		"START,Ready,-",
+		"Ready,Busy,request",
+		"Busy,Ready,response",
+	)
+
+	p, deadlocks, err := Product(client, server)
+	if err != nil {
+		t.Fatalf("Product() error = %v", err)
+	}
+	wantNodes := []string{"END_Ready", "END_START", "Idle_Ready", "Idle_START", "START", "START_Ready", "Waiting_Busy"}
+	if got := p.NodeNames(); !reflect.DeepEqual(got, wantNodes) {
+		t.Errorf("NodeNames() = %v, want %v", got, wantNodes)
+	}
+	wantIdle := []Edge{
+		{From: "Idle_Ready", To: "Waiting_Busy", Description: "request", Event: "request"},
+		{From: "Idle_Ready", To: "END_Ready", Description: "quit", Event: "quit"},
+	}
+	if !reflect.DeepEqual(p.Nodes["Idle_Ready"], wantIdle) {
+		t.Errorf("edges of Idle_Ready = %v, want %v", p.Nodes["Idle_Ready"], wantIdle)
+	}
+	// the client can quit while the server never finishes
+	if want := []string{"END_Ready"}; !reflect.DeepEqual(deadlocks, want) {
+		t.Errorf("deadlocks = %v, want %v", deadlocks, want)
+	}
+}
+
+func TestProductSharedEventBlocks(t *testing.T) {
+	// the server never answers, so the client waits forever
+	client := loadGraph(t, "START,Waiting,request", "Waiting,END,response")
+	server := loadGraph(t, "START,Busy,request", "Busy,END,-", "Other,END,response")
+
+	p, deadlocks, err := Product(client, server)
+	if err != nil {
+		t.Fatalf("Product() error = %v", err)
+	}
+	if want := []string{"Waiting_END"}; !reflect.DeepEqual(deadlocks, want) {
+		t.Errorf("deadlocks = %v, want %v", deadlocks, want)
+	}
+	if _, ok := p.Nodes["END"]; ok {
+		t.Error("END should not be reachable")
+	}
+}
+
+func TestProductErrors(t *testing.T) {
+	if _, _, err := Product(loadGraph(t, "A,B,x"), loadGraph(t, "START,B,x")); err == nil {
+		t.Error("expected error for a graph without START")
+	}
+	a := loadGraph(t, "START,A_B,x", "START,A,y")
+	b := loadGraph(t, "START,C,x", "START,B_C,y")
+	if _, _, err := Product(a, b); err == nil {
+		t.Error("expected error for colliding product state names")
+	}
+}
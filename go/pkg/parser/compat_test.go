@@ -0,0 +1,64 @@
+package parser_test
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	parser "sqirvy.xyz/state-gen/pkg/parser"
+)
+
+// The exported API is pinned here so an incompatible change fails to
+// compile. Additions are fine; changes to these signatures need a new
+// major version of the module.
+var (
+	_ func(io.Reader, ...parser.Option) (*parser.Diagram, error) = parser.Parse
+	_ func(int) parser.Option                                    = parser.WithMaxLines
+	_ func(int) parser.Option                                    = parser.WithMaxLineLength
+	_ func(*parser.Diagram) []string                             = (*parser.Diagram).CSV
+	_ func(parser.Diagnostic) string                             = parser.Diagnostic.String
+	_ func(*os.File, bool) ([]string, error)                     = parser.ProcessStateFile
+	_ func([]byte) []byte                                        = parser.Format
+	_ func() *parser.Parser                                      = parser.NewParser
+
+	_ = parser.Diagram{Transitions: []parser.Transition{}, Skipped: []parser.Diagnostic{}}
+	_ = parser.Transition{From: "", To: "", Description: "", Line: 0}
+	_ = parser.Diagnostic{Line: 0, Text: ""}
+)
+
+// TestParseMatchesProcessStateFile checks that the structured API and the
+// original CSV API agree on the test diagrams.
+func TestParseMatchesProcessStateFile(t *testing.T) {
+	for _, name := range []string{"t1.md", "t2.md", "t3.md", "s1.md", "order.md", "input.txt"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open("../../../test/" + name)
+			if err != nil {
+				t.Fatalf("Failed to open test file: %v", err)
+			}
+			defer f.Close()
+			legacy, _ := parser.ProcessStateFile(f, false)
+
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			d, err := parser.Parse(f)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			csv := d.CSV()
+			if len(csv) != len(legacy) {
+				t.Fatalf("Parse() found %d transitions, ProcessStateFile %d", len(csv), len(legacy))
+			}
+			for i := range csv {
+				// ProcessStateFile keeps the space after the colon
+				parts := strings.SplitN(legacy[i], ",", 3)
+				parts[2] = strings.TrimSpace(parts[2])
+				if want := strings.Join(parts, ","); csv[i] != want {
+					t.Errorf("transition %d = %q, want %q", i, csv[i], want)
+				}
+			}
+		})
+	}
+}
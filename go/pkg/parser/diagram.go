@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Transition is a state transition found in a diagram.
+type Transition struct {
+	// From is the source state, START for [*]
+	From string `json:"from"`
+	// To is the destination state, END for [*]
+	To string `json:"to"`
+	// Description is the text after the colon with surrounding space
+	// removed, or empty if the transition has none
+	Description string `json:"description,omitempty"`
+	// Line is the 1-based line number of the transition
+	Line int `json:"line"`
+}
+
+// Diagnostic is a non-empty line that is not a transition, such as other
+// Mermaid syntax, an invalid state name or a malformed transition.
+type Diagnostic struct {
+	// Line is the 1-based line number
+	Line int `json:"line"`
+	// Text is the line with surrounding space removed
+	Text string `json:"text"`
+}
+
+// String returns the diagnostic as "line N: invalid input: text".
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d: invalid input: %s", d.Line, d.Text)
+}
+
+// Diagram is the parsed form of a Mermaid state diagram: its transitions
+// in the order they appear and the lines that were skipped.
+type Diagram struct {
+	Transitions []Transition `json:"transitions"`
+	Skipped     []Diagnostic `json:"skipped"`
+}
+
+// CSV returns the transitions as "from,to,description" lines, the input
+// format of graph.Graph.Load. Transitions without a description get the
+// "-" placeholder.
+func (d *Diagram) CSV() []string {
+	lines := make([]string, 0, len(d.Transitions))
+	for _, t := range d.Transitions {
+		desc := t.Description
+		if desc == "" {
+			desc = placeholder
+		}
+		lines = append(lines, fmt.Sprintf("%s,%s,%s", t.From, t.To, desc))
+	}
+	return lines
+}
+
+// options holds the settings Parse can be configured with.
+type options struct {
+	maxLines      int
+	maxLineLength int
+}
+
+// Option configures Parse.
+type Option func(*options)
+
+// WithMaxLines limits the number of lines Parse reads. The default is 10000.
+// The limit must be positive.
+func WithMaxLines(n int) Option {
+	return func(o *options) {
+		o.maxLines = n
+	}
+}
+
+// WithMaxLineLength limits the length in bytes of a line Parse reads. The
+// default is 1000. The limit must be positive.
+func WithMaxLineLength(n int) Option {
+	return func(o *options) {
+		o.maxLineLength = n
+	}
+}
+
+// Parse reads a Mermaid state diagram and returns its transitions. Lines
+// that are not transitions are recorded in Diagram.Skipped rather than
+// treated as errors, so diagrams can contain other Mermaid syntax and
+// markdown. It is an error if a limit is not positive, or if the input
+// exceeds the configured limits or contains no transition at all.
+func Parse(r io.Reader, opts ...Option) (*Diagram, error) {
+	o := options{maxLines: maxInputLines, maxLineLength: maxLineLength}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxLines <= 0 {
+		return nil, fmt.Errorf("invalid maximum of %d lines", o.maxLines)
+	}
+	if o.maxLineLength <= 0 {
+		return nil, fmt.Errorf("invalid maximum line length of %d bytes", o.maxLineLength)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, o.maxLineLength), o.maxLineLength)
+
+	p := NewParser()
+	d := &Diagram{}
+	lineCount := 0
+	for scanner.Scan() {
+		lineCount++
+		if lineCount > o.maxLines {
+			return nil, fmt.Errorf("input exceeds maximum of %d lines", o.maxLines)
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		from, to, desc, ok := p.matchTransition(line)
+		if !ok {
+			d.Skipped = append(d.Skipped, Diagnostic{Line: lineCount, Text: line})
+			continue
+		}
+		d.Transitions = append(d.Transitions, Transition{
+			From:        from,
+			To:          to,
+			Description: strings.TrimSpace(desc),
+			Line:        lineCount,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading input: %w", err)
+	}
+
+	if len(d.Transitions) == 0 {
+		return nil, fmt.Errorf("graph must contain at least one transition")
+	}
+	return d, nil
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := "stateDiagram-v2\n\n[*] --> Idle\nIdle --> Busy :  go  \n1Bad --> Idle\nBusy --> [*]\n"
+	d, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := &Diagram{
+		Transitions: []Transition{
+			{From: "START", To: "Idle", Line: 3},
+			{From: "Idle", To: "Busy", Description: "go", Line: 4},
+			{From: "Busy", To: "END", Line: 6},
+		},
+		Skipped: []Diagnostic{
+			{Line: 1, Text: "stateDiagram-v2"},
+			{Line: 5, Text: "1Bad --> Idle"},
+		},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("Parse() = %+v, want %+v", d, want)
+	}
+	if got, want := d.CSV(), []string{"START,Idle,-", "Idle,Busy,go", "Busy,END,-"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CSV() = %v, want %v", got, want)
+	}
+	if got := d.Skipped[1].String(); got != "line 5: invalid input: 1Bad --> Idle" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	input := "A --> B\nB --> C\nC --> D\n"
+	if _, err := Parse(strings.NewReader(input), WithMaxLines(2)); err == nil {
+		t.Error("expected error for too many lines")
+	}
+	if _, err := Parse(strings.NewReader(input), WithMaxLines(3)); err != nil {
+		t.Errorf("Parse() error = %v", err)
+	}
+	if _, err := Parse(strings.NewReader(input), WithMaxLineLength(4)); err == nil {
+		t.Error("expected error for a line that is too long")
+	}
+}
+
+func TestParseInvalidOptions(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"zero lines":       WithMaxLines(0),
+		"negative lines":   WithMaxLines(-1),
+		"zero line length": WithMaxLineLength(0),
+		"negative length":  WithMaxLineLength(-1),
+	} {
+		if _, err := Parse(strings.NewReader("A --> B\n"), opt); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseNoTransitions(t *testing.T) {
+	if _, err := Parse(strings.NewReader("stateDiagram-v2\n")); err == nil {
+		t.Error("expected error for a diagram without transitions")
+	}
+}
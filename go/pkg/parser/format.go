@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// indentUnit is the indentation applied per level of composite state nesting.
+const indentUnit = "  "
+
+// compositeOpenRegex matches the line that opens a composite state, e.g.
+// "state Active {".
+var compositeOpenRegex = regexp.MustCompile(`^state\s.*\{$`)
+
+// Format normalizes the layout of a Mermaid state diagram. Transitions are
+// rewritten in the canonical form "From --> To : Description", lines are
+// indented by composite state depth, trailing whitespace is removed and runs
+// of blank lines collapse to one. Front matter between --- markers is left
+// untouched. If the input contains ```mermaid fences, only the fenced blocks
+// are formatted and the surrounding markdown is copied as is. Only
+// "state X {" lines and bare "}" lines change the depth, so braces in
+// comments and notes do not. Empty input is returned unchanged.
+func Format(src []byte) []byte {
+	if len(src) == 0 {
+		return src
+	}
+	lines := strings.Split(strings.TrimRight(string(src), "\n"), "\n")
+	fenced := false
+	for _, line := range lines {
+		if isFenceOpen(line) {
+			fenced = true
+			break
+		}
+	}
+
+	p := NewParser()
+	var out []string
+	inDiagram := !fenced
+	inFrontMatter := false
+	seenContent := false
+	depth := 0
+	blank := false
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// markdown outside the fenced blocks
+		if !inDiagram {
+			out = append(out, line)
+			if isFenceOpen(line) {
+				inDiagram, seenContent, depth, blank = true, false, 0, false
+			}
+			continue
+		}
+		if fenced && trimmed == "```" {
+			if blank && len(out) > 0 && out[len(out)-1] == "" {
+				out = out[:len(out)-1]
+			}
+			out = append(out, trimmed)
+			inDiagram = false
+			continue
+		}
+
+		// front matter is YAML, so its indentation is significant
+		if trimmed == "---" && (inFrontMatter || !seenContent) {
+			inFrontMatter = !inFrontMatter
+			seenContent = true
+			blank = false
+			out = append(out, trimmed)
+			continue
+		}
+		if inFrontMatter {
+			out = append(out, strings.TrimRight(line, " \t"))
+			continue
+		}
+
+		if trimmed == "" {
+			if seenContent && !blank {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		seenContent = true
+		blank = false
+
+		if trimmed == "}" && depth > 0 {
+			depth--
+		}
+		out = append(out, strings.Repeat(indentUnit, depth)+p.formatLine(trimmed))
+		if compositeOpenRegex.MatchString(trimmed) {
+			depth++
+		}
+	}
+
+	// drop a trailing blank line at the end of an unfenced diagram
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return []byte(strings.Join(out, "\n") + "\n")
+}
+
+// formatLine rewrites a transition in canonical form and returns any other
+// line unchanged.
+func (p *Parser) formatLine(line string) string {
+	matches := p.transitionRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return line
+	}
+	formatted := matches[1] + " --> " + matches[2]
+	if desc := strings.TrimSpace(matches[3]); desc != "" {
+		formatted += " : " + desc
+	}
+	return formatted
+}
+
+// isFenceOpen reports whether line opens a ```mermaid code block.
+func isFenceOpen(line string) bool {
+	return strings.TrimSpace(line) == "```mermaid"
+}
@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"bufio"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "transition spacing",
+			input: "A-->B\nB -->C:go\n  C   -->   [*]  :   done  \n",
+			want:  "A --> B\nB --> C : go\nC --> [*] : done\n",
+		},
+		{
+			name:  "composite indentation and blank lines",
+			input: "stateDiagram-v2\n\n\nstate X {\n[*] --> Y\n    }\n\n",
+			want:  "stateDiagram-v2\n\nstate X {\n  [*] --> Y\n}\n",
+		},
+		{
+			name:  "braces in comments and notes",
+			input: "state X {\n%% see {\nnote right of Y : a } b {\n[*] --> Y\n%% } done\n}\nA --> B\n",
+			want:  "state X {\n  %% see {\n  note right of Y : a } b {\n  [*] --> Y\n  %% } done\n}\nA --> B\n",
+		},
+		{
+			name:  "empty input",
+			input: "",
+			want:  "",
+		},
+		{
+			name:  "front matter untouched",
+			input: "---\nconfig:\n  theme: dark\n---\nstateDiagram-v2\nA-->B\n",
+			want:  "---\nconfig:\n  theme: dark\n---\nstateDiagram-v2\nA --> B\n",
+		},
+		{
+			name:  "markdown outside fences untouched",
+			input: "# Title\n\n  some   text\n```mermaid\nA-->B\n\n```\nmore  \n",
+			want:  "# Title\n\n  some   text\n```mermaid\nA --> B\n```\nmore  \n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(Format([]byte(tt.input)))
+			if got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+			if again := string(Format([]byte(got))); again != got {
+				t.Errorf("Format() is not idempotent: %q", again)
+			}
+		})
+	}
+}
+
+// TestFormatPreservesTransitions verifies formatting the test diagrams
+// does not change what the parser extracts from them.
+func TestFormatPreservesTransitions(t *testing.T) {
+	for _, name := range []string{"t1.md", "t2.md", "t3.md", "order.md"} {
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(testdir + name)
+			if err != nil {
+				t.Fatalf("Failed to read test file: %v", err)
+			}
+
+			parse := func(b []byte) []string {
+				valid, _, err := processInput(bufio.NewScanner(strings.NewReader(string(b))))
+				if err != nil {
+					t.Fatalf("processInput() error = %v", err)
+				}
+				return valid
+			}
+
+			before := parse(src)
+			after := parse(Format(src))
+			if len(before) != len(after) {
+				t.Fatalf("got %d transitions after formatting, want %d", len(after), len(before))
+			}
+			for i := range before {
+				// the parser keeps the whitespace before a description
+				b := strings.Split(before[i], ",")
+				a := strings.Split(after[i], ",")
+				for j := range b {
+					b[j] = strings.TrimSpace(b[j])
+					a[j] = strings.TrimSpace(a[j])
+				}
+				if !reflect.DeepEqual(a, b) {
+					t.Errorf("transition %d = %v, want %v", i, a, b)
+				}
+			}
+		})
+	}
+}
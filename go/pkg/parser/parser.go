@@ -3,6 +3,11 @@
 // and outputting valid transitions in CSV format. Anything not a valid transition
 // will be logged to stderr. This includes other Mermaid syntax, invalid state names,
 // invalid transition lines, and invalid descriptions.
+//
+// Parse returns the structured form directly: a Diagram holding each
+// Transition with its line number, and a Diagnostic for every line that was
+// skipped. Parse is configured with functional options such as
+// WithMaxLines. The exported API follows the module's semantic version.
 package parser
 
 import (
@@ -73,6 +78,28 @@ func (p *Parser) isValidDescription(desc string) bool {
 	return p.descriptionRegex.MatchString(desc)
 }
 
+// matchTransition parses a trimmed line as a transition. [*] is returned
+// as START or END, and the description is returned as written, including
+// the space after the colon. ok is false if the line is not a valid
+// transition.
+func (p *Parser) matchTransition(line string) (from, to, desc string, ok bool) {
+	matches := p.transitionRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return "", "", "", false
+	}
+	from, to, desc = matches[1], matches[2], matches[3]
+	if from == "[*]" {
+		from = "START"
+	}
+	if to == "[*]" {
+		to = "END"
+	}
+	if !p.isValidState(from) || !p.isValidState(to) || !p.isValidDescription(desc) {
+		return "", "", "", false
+	}
+	return from, to, desc, true
+}
+
 func (p *Parser) parseMermaid(lines []string) ([]string, []string, error) {
 	var validResults []string
 	var invalidResults []string
@@ -84,32 +111,15 @@ func (p *Parser) parseMermaid(lines []string) ([]string, []string, error) {
 			continue
 		}
 
-		matches := p.transitionRegex.FindStringSubmatch(line)
-		if matches != nil {
-			fromState := matches[1]
-			toState := matches[2]
-			description := ""
-			if len(matches) > 3 {
-				description = matches[3]
-			}
-
-			if fromState == "[*]" {
-				fromState = "START"
-			}
-			if toState == "[*]" {
-				toState = "END"
-			}
-
-			if p.isValidState(fromState) && p.isValidState(toState) &&
-				p.isValidDescription(description) {
-				desc := description
-				if description == "" {
-					desc = placeholder
-				}
-				validResults = append(validResults,
-					fmt.Sprintf("%s,%s,%s",
-						fromState, toState, desc))
+		fromState, toState, description, ok := p.matchTransition(line)
+		if ok {
+			desc := description
+			if description == "" {
+				desc = placeholder
 			}
+			validResults = append(validResults,
+				fmt.Sprintf("%s,%s,%s",
+					fromState, toState, desc))
 		} else {
 			invalidResults = append(invalidResults,
 				fmt.Sprintf("%sInvalid input: %s",
@@ -0,0 +1,87 @@
+package statemachine
+
+import (
+	"context"
+)
+
+// Activity is a do-activity: long-running work, such as polling a device,
+// that runs in its own goroutine while the machine is in a state.
+type Activity[Input any] struct {
+	// Run does the work. It must return once ctx is cancelled, which
+	// happens when the machine leaves the state.
+	Run func(ctx context.Context) error
+	// Result, if set, is called with the error Run returned when Run ends
+	// on its own, not because the state was left. If ok is true the input
+	// is posted to the machine and processed by the next Execute.
+	Result func(err error) (input Input, ok bool)
+}
+
+// runningActivity is the do-activity of the current state.
+type runningActivity struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// SetActivity declares the do-activity of a state. It starts whenever the
+// machine enters the state, including through SetInitialState, and is
+// cancelled when the machine leaves it; a transition from the state to
+// itself restarts it only in event table mode, where such transitions
+// are taken like any other. Leaving the state waits for Run to return, so
+// no activity outlives its state. If the state is the current one, the
+// activity starts now.
+func (sm *StateMachine[Model, Input]) SetActivity(state StateKey, activity Activity[Input]) {
+	if sm.activities == nil {
+		sm.activities = make(map[StateKey]Activity[Input])
+	}
+	sm.activities[state] = activity
+	if sm.currentState != nil && sm.currentState.GetKey() == state {
+		sm.StopActivity()
+		sm.startActivity()
+	}
+}
+
+// StopActivity cancels the current state's do-activity, if it is running,
+// and waits for it to return. Call it before abandoning a machine whose
+// current state has a do-activity. Unlike the other methods it is safe to
+// call from any goroutine.
+func (sm *StateMachine[Model, Input]) StopActivity() {
+	sm.activityMu.Lock()
+	running := sm.activity
+	sm.activity = nil
+	if running != nil {
+		running.cancel()
+	}
+	sm.activityMu.Unlock()
+	if running != nil {
+		<-running.done
+	}
+}
+
+// startActivity starts the current state's do-activity, if it has one.
+func (sm *StateMachine[Model, Input]) startActivity() {
+	activity, ok := sm.activities[sm.currentState.GetKey()]
+	if !ok || activity.Run == nil || sm.Done() {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	running := &runningActivity{cancel: cancel, done: make(chan struct{})}
+	sm.activityMu.Lock()
+	sm.activity = running
+	sm.activityMu.Unlock()
+
+	go func() {
+		defer close(running.done)
+		err := activity.Run(ctx)
+		if activity.Result == nil {
+			return
+		}
+		input, ok := activity.Result(err)
+		// the lock orders this check with StopActivity's cancel, so
+		// nothing is posted once the state has been left
+		sm.activityMu.Lock()
+		defer sm.activityMu.Unlock()
+		if ok && ctx.Err() == nil {
+			sm.Post(input)
+		}
+	}()
+}
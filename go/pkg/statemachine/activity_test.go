@@ -0,0 +1,148 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newActivityMachine builds a machine that moves to the state named by its
+// input, except that "noop" stays put.
+func newActivityMachine(t *testing.T) *StateMachine[testModel, string] {
+	t.Helper()
+	sm := NewStateMachine[testModel, string](&testModel{0}, "activity")
+	move := func(current *State[testModel, string], model *testModel, input string) (StateKey, error) {
+		if input == "noop" {
+			return current.Key, nil
+		}
+		return StateKey(input), nil
+	}
+	for _, key := range []StateKey{"Idle", "Polling", "Ready", "Failed"} {
+		if err := sm.AddState(NewState(key, move, nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	return sm
+}
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestActivityCancelledOnExit(t *testing.T) {
+	sm := newActivityMachine(t)
+	started := make(chan struct{})
+	stopped := false
+	sm.SetActivity("Polling", Activity[string]{
+		Run: func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			time.Sleep(5 * time.Millisecond)
+			stopped = true
+			return ctx.Err()
+		},
+		Result: func(err error) (string, bool) {
+			return "Failed", true
+		},
+	})
+
+	if _, err := sm.Execute(&testModel{0}, "Polling"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	<-started
+	running := sm.activity
+	// staying in the state keeps the activity running
+	if _, err := sm.Execute(&testModel{0}, "noop"); err != nil || stopped {
+		t.Fatalf("Execute() error = %v, stopped = %v", err, stopped)
+	}
+
+	// leaving waits for the activity, which posts nothing once cancelled
+	if key, err := sm.Execute(&testModel{0}, "Idle"); err != nil || key != "Idle" {
+		t.Fatalf("Execute() = %v, %v, want Idle", key, err)
+	}
+	if !stopped {
+		t.Error("activity still running after the state was left")
+	}
+	if n := sm.queue.len(); n != 0 {
+		t.Errorf("%d inputs posted by a cancelled activity", n)
+	}
+	select {
+	case <-running.done:
+	default:
+		t.Error("activity goroutine still running after the state was left")
+	}
+}
+
+func TestActivityPostsResult(t *testing.T) {
+	sm := newActivityMachine(t)
+	finish := make(chan error)
+	sm.SetActivity("Polling", Activity[string]{
+		Run: func(ctx context.Context) error {
+			select {
+			case err := <-finish:
+				return err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+		Result: func(err error) (string, bool) {
+			if err != nil {
+				return "Failed", true
+			}
+			return "Ready", true
+		},
+	})
+
+	for _, tc := range []struct {
+		err  error
+		want StateKey
+	}{
+		{nil, "Ready"},
+		{errors.New("device gone"), "Failed"},
+	} {
+		if _, err := sm.Execute(&testModel{0}, "Polling"); err != nil {
+			t.Fatalf("Execute() error = %v", err)
+		}
+		finish <- tc.err
+		waitFor(t, "the result to be posted", func() bool { return sm.queue.len() == 1 })
+
+		// the posted input is processed after the next one
+		if key, err := sm.Execute(&testModel{0}, "noop"); err != nil || key != tc.want {
+			t.Errorf("Execute() = %v, %v, want %v", key, err, tc.want)
+		}
+	}
+	sm.StopActivity()
+}
+
+func TestActivityInitialState(t *testing.T) {
+	sm := newActivityMachine(t)
+	runs := make(chan struct{}, 2)
+	sm.SetActivity("Idle", Activity[string]{
+		Run: func(ctx context.Context) error {
+			runs <- struct{}{}
+			<-ctx.Done()
+			return nil
+		},
+	})
+	// Idle is the current state, so the activity starts at once
+	<-runs
+
+	if err := sm.SetInitialState("Idle"); err != nil {
+		t.Fatalf("SetInitialState() error = %v", err)
+	}
+	<-runs
+	sm.StopActivity()
+	sm.StopActivity()
+	if sm.activity != nil {
+		t.Error("activity still recorded after StopActivity")
+	}
+}
@@ -0,0 +1,77 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CompletionTable maps a state to the target of its completion
+// transition, an unlabelled transition that needs no input.
+type CompletionTable map[StateKey]StateKey
+
+// CompletionLoopError is returned by Execute when completion transitions
+// lead back to a state they already passed through, so the machine would
+// never settle in a state that waits for input. The machine is left in
+// that state.
+type CompletionLoopError struct {
+	// Loop lists the states of the loop, starting and ending with the
+	// state that was reached twice
+	Loop []StateKey
+}
+
+// Error lists the states of the loop.
+func (e *CompletionLoopError) Error() string {
+	steps := make([]string, len(e.Loop))
+	for i, key := range e.Loop {
+		steps[i] = string(key)
+	}
+	return fmt.Sprintf("completion transitions never settle: %s", strings.Join(steps, " -> "))
+}
+
+// SetCompletions installs completion transitions. Whenever Execute moves
+// the machine into a state with a completion transition, that state's
+// action runs straight away with the zero Input, and the machine then
+// moves on to the completion target, ignoring the key the action
+// returns. This repeats until the machine reaches a state without one,
+// which waits for the next input. If an action fails the machine stays in
+// its state and Execute returns the error, unless the state has an error
+// target to move to instead. The initial state's completion
+// transition is not taken, as the machine is not moved into it. Passing
+// nil removes the completion transitions.
+func (sm *StateMachine[Model, Input]) SetCompletions(table CompletionTable) {
+	sm.completions = table
+}
+
+// complete takes completion transitions from the current state until it
+// reaches a state without one, and returns that state's key.
+func (sm *StateMachine[Model, Input]) complete(ctx context.Context, model *Model) (StateKey, error) {
+	key := sm.currentState.GetKey()
+	visited := []StateKey{key}
+	for {
+		next, ok := sm.completions[key]
+		if !ok || sm.final[key] {
+			return key, nil
+		}
+		newState, exists := sm.states[next]
+		if !exists {
+			return "", fmt.Errorf("state %v does not exist", next)
+		}
+
+		var input Input
+		if _, err := sm.run(ctx, model, input); err != nil {
+			if next, err = sm.fail(err); err != nil {
+				return "", err
+			}
+		} else {
+			sm.transition(newState)
+		}
+		for i, v := range visited {
+			if v == next {
+				return "", &CompletionLoopError{Loop: append(visited[i:], next)}
+			}
+		}
+		visited = append(visited, next)
+		key = next
+	}
+}
@@ -0,0 +1,103 @@
+package statemachine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newCompletionMachine builds a machine where Idle moves to Check on any
+// input, and Check and Ready complete to Ready and Waiting. Every action
+// appends its state and input to the trace.
+func newCompletionMachine(t *testing.T, trace *[]string) *StateMachine[testModel, string] {
+	t.Helper()
+	sm := NewStateMachine[testModel, string](&testModel{0}, "completion")
+	action := func(next StateKey) ActionFunc[testModel, string] {
+		return func(current *State[testModel, string], model *testModel, input string) (StateKey, error) {
+			*trace = append(*trace, current.Key.String()+"("+input+")")
+			if input == "fail" || (current.Key == "Ready" && model.value < 0) {
+				return "", errors.New("failed")
+			}
+			return next, nil
+		}
+	}
+	for _, s := range []*State[testModel, string]{
+		NewState("Idle", action("Check"), nil),
+		NewState("Check", action("ignored"), nil),
+		NewState("Ready", action("ignored"), nil),
+		NewState("Waiting", action("Idle"), nil),
+	} {
+		if err := sm.AddState(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	sm.SetCompletions(CompletionTable{"Check": "Ready", "Ready": "Waiting"})
+	return sm
+}
+
+func TestExecuteCompletions(t *testing.T) {
+	var trace []string
+	sm := newCompletionMachine(t, &trace)
+	coverage := NewCoverage()
+	sm.SetCoverage(coverage)
+
+	key, err := sm.Execute(&testModel{0}, "go")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if key != "Waiting" || sm.GetCurrentState().Key != "Waiting" {
+		t.Errorf("Execute() = %v, want Waiting", key)
+	}
+	if want := []string{"Idle(go)", "Check()", "Ready()"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("actions = %v, want %v", trace, want)
+	}
+	if coverage.Count("Check", "Ready") != 1 || coverage.Count("Ready", "Waiting") != 1 {
+		t.Errorf("completion transitions not recorded: %v", coverage.Counts())
+	}
+
+	// Waiting has no completion transition and waits for input
+	if key, err := sm.Execute(&testModel{0}, "again"); err != nil || key != "Idle" {
+		t.Errorf("Execute() = %v, %v, want Idle", key, err)
+	}
+}
+
+func TestExecuteCompletionError(t *testing.T) {
+	var trace []string
+	sm := newCompletionMachine(t, &trace)
+	_, err := sm.Execute(&testModel{-1}, "go")
+	if err == nil || err.Error() != "failed" {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if sm.GetCurrentState().Key != "Ready" {
+		t.Errorf("current state = %v, want Ready", sm.GetCurrentState().Key)
+	}
+}
+
+func TestExecuteCompletionLoop(t *testing.T) {
+	var trace []string
+	sm := newCompletionMachine(t, &trace)
+	sm.SetCompletions(CompletionTable{"Check": "Ready", "Ready": "Waiting", "Waiting": "Check"})
+
+	_, err := sm.Execute(&testModel{0}, "go")
+	var loop *CompletionLoopError
+	if !errors.As(err, &loop) {
+		t.Fatalf("Execute() error = %v, want CompletionLoopError", err)
+	}
+	if want := []StateKey{"Check", "Ready", "Waiting", "Check"}; !reflect.DeepEqual(loop.Loop, want) {
+		t.Errorf("Loop = %v, want %v", loop.Loop, want)
+	}
+	if want := "completion transitions never settle: Check -> Ready -> Waiting -> Check"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+}
+
+func TestExecuteEventCompletions(t *testing.T) {
+	sm := newEventMachine(t)
+	sm.SetCompletions(CompletionTable{"Done": "Idle"})
+	if key, err := sm.Execute(&testModel{0}, "submit"); err != nil || key != "Processing" {
+		t.Fatalf("Execute() = %v, %v, want Processing", key, err)
+	}
+	if key, err := sm.Execute(&testModel{0}, "accept"); err != nil || key != "Idle" {
+		t.Errorf("Execute() = %v, %v, want Idle", key, err)
+	}
+}
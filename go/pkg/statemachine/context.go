@@ -0,0 +1,67 @@
+package statemachine
+
+import (
+	"context"
+)
+
+// ContextActionFunc is a state action that also receives a context, so
+// actions that do I/O can honor cancellation and deadlines and read
+// request-scoped values. It is otherwise the same as ActionFunc.
+type ContextActionFunc[Model any, Input any] func(ctx context.Context, current *State[Model, Input], model *Model, input Input) (key StateKey, err error)
+
+// AdaptAction turns an ActionFunc into a ContextActionFunc that ignores
+// the context.
+func AdaptAction[Model any, Input any](action ActionFunc[Model, Input]) ContextActionFunc[Model, Input] {
+	return func(ctx context.Context, current *State[Model, Input], model *Model, input Input) (StateKey, error) {
+		return action(current, model, input)
+	}
+}
+
+// NewContextState creates a new state with the given key and context-aware action.
+func NewContextState[Model any, Input any](
+	key StateKey,
+	act ContextActionFunc[Model, Input],
+	data *interface{},
+) *State[Model, Input] {
+	if act == nil || key == "" {
+		return nil
+	}
+	return &State[Model, Input]{
+		Key:           key,
+		ContextAction: act,
+		Data:          data,
+	}
+}
+
+// action returns the state's action as a ContextActionFunc, or nil if it
+// has none.
+func (s *State[Model, Input]) action() ContextActionFunc[Model, Input] {
+	if s.ContextAction != nil {
+		return s.ContextAction
+	}
+	if s.Action != nil {
+		return AdaptAction(s.Action)
+	}
+	return nil
+}
+
+// ExecuteContext performs the state's action with a context and returns
+// the key of the next state.
+func (s *State[Model, Input]) ExecuteContext(ctx context.Context, model *Model, input Input) (key StateKey, err error) {
+	return s.action()(ctx, s, model, input)
+}
+
+// ExecuteContext is Execute with a context, which is passed to every
+// action and middleware. Execute stops with the context's error if it is
+// done before an input is processed; a retry backoff ends early with the
+// action's last error.
+func (sm *StateMachine[Model, Input]) ExecuteContext(ctx context.Context, model *Model, input Input) (key StateKey, err error) {
+	key, err = sm.step(ctx, model, input)
+	if err == nil {
+		key, err = sm.drain(ctx, model, key)
+	}
+	if err != nil {
+		sm.queue.clear()
+	}
+	return key, err
+}
@@ -0,0 +1,79 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// ctxKey is the type of the request-scoped value in the tests.
+type ctxKey struct{}
+
+func TestExecuteContext(t *testing.T) {
+	sm := NewStateMachine[testModel, int](&testModel{0}, "context")
+	var seen any
+	a := func(ctx context.Context, current *State[testModel, int], model *testModel, input int) (StateKey, error) {
+		seen = ctx.Value(ctxKey{})
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		return "B", nil
+	}
+	// a plain ActionFunc, adapted
+	b := func(current *State[testModel, int], model *testModel, input int) (StateKey, error) {
+		return "A", nil
+	}
+	for _, s := range []*State[testModel, int]{
+		NewContextState("A", a, nil),
+		NewContextState("B", AdaptAction(b), nil),
+	} {
+		if err := sm.AddState(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	if key, err := sm.ExecuteContext(ctx, &testModel{0}, 0); err != nil || key != "B" {
+		t.Fatalf("ExecuteContext() = %v, %v, want B", key, err)
+	}
+	if seen != "request" {
+		t.Errorf("action saw %v, want request", seen)
+	}
+	if key, err := sm.Execute(&testModel{0}, 0); err != nil || key != "A" {
+		t.Fatalf("Execute() = %v, %v, want A", key, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := sm.ExecuteContext(canceled, &testModel{0}, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("ExecuteContext() error = %v, want %v", err, context.Canceled)
+	}
+	if sm.GetCurrentState().Key != "A" {
+		t.Errorf("current state = %v, want A", sm.GetCurrentState().Key)
+	}
+}
+
+// blockingClock never fires, so only a done context ends a backoff.
+type blockingClock struct{}
+
+func (blockingClock) After(d time.Duration) <-chan time.Time {
+	return nil
+}
+
+func TestExecuteContextRetryCanceled(t *testing.T) {
+	var seen error
+	sm := newFailureMachine(t, &seen)
+	sm.SetClock(blockingClock{})
+	sm.SetRetryPolicy("Processing", RetryPolicy{MaxAttempts: 3, Backoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	model := &testModel{5}
+	if _, err := sm.ExecuteContext(ctx, model, 0); !errors.Is(err, errFlaky) {
+		t.Errorf("ExecuteContext() error = %v, want %v", err, errFlaky)
+	}
+	if model.value != 4 {
+		t.Errorf("action ran %d times, want 1", 5-model.value)
+	}
+}
@@ -0,0 +1,150 @@
+package statemachine
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Transition identifies a move from one state to another.
+type Transition struct {
+	From StateKey `json:"from"`
+	To   StateKey `json:"to"`
+}
+
+// Coverage counts the transitions taken by one or more state machines.
+// It is safe for concurrent use, so a single Coverage can be shared by
+// machines running in parallel tests.
+type Coverage struct {
+	mu     sync.Mutex
+	counts map[Transition]int
+	loops  map[StateKey]bool
+}
+
+// coverageEntry is the serialized form of a single transition count.
+type coverageEntry struct {
+	From  StateKey `json:"from"`
+	To    StateKey `json:"to"`
+	Count int      `json:"count"`
+}
+
+// NewCoverage creates an empty coverage recorder.
+func NewCoverage() *Coverage {
+	return &Coverage{
+		counts: make(map[Transition]int),
+	}
+}
+
+// Record counts one transition from one state to another.
+func (c *Coverage) Record(from, to StateKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[Transition{From: from, To: to}]++
+}
+
+// SetSelfLoops names the states that have a self-transition in the
+// diagram. An action that returns its own key looks the same as one that
+// stays put, so Execute only records it as a self-transition for these
+// states. The states replace any set before.
+func (c *Coverage) SetSelfLoops(keys ...StateKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loops = make(map[StateKey]bool, len(keys))
+	for _, k := range keys {
+		c.loops[k] = true
+	}
+}
+
+// selfLoop reports whether staying in key counts as a self-transition.
+func (c *Coverage) selfLoop(key StateKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.loops[key]
+}
+
+// Count returns the number of times the transition was recorded.
+func (c *Coverage) Count(from, to StateKey) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[Transition{From: from, To: to}]
+}
+
+// Counts returns a copy of all recorded transition counts.
+func (c *Coverage) Counts() map[Transition]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[Transition]int, len(c.counts))
+	for t, n := range c.counts {
+		counts[t] = n
+	}
+	return counts
+}
+
+// Merge adds the counts recorded by other into c.
+func (c *Coverage) Merge(other *Coverage) {
+	if other == nil || other == c {
+		return
+	}
+	counts := other.Counts()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for t, n := range counts {
+		c.counts[t] += n
+	}
+}
+
+// MarshalJSON encodes the counts as a list of from, to, count entries
+// sorted by transition.
+func (c *Coverage) MarshalJSON() ([]byte, error) {
+	counts := c.Counts()
+	entries := make([]coverageEntry, 0, len(counts))
+	for t, n := range counts {
+		entries = append(entries, coverageEntry{From: t.From, To: t.To, Count: n})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].From != entries[j].From {
+			return entries[i].From < entries[j].From
+		}
+		return entries[i].To < entries[j].To
+	})
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON decodes counts written by MarshalJSON, adding them to any
+// counts already recorded.
+func (c *Coverage) UnmarshalJSON(data []byte) error {
+	var entries []coverageEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[Transition]int)
+	}
+	for _, e := range entries {
+		if e.Count < 0 {
+			return fmt.Errorf("negative count for %v -> %v", e.From, e.To)
+		}
+		c.counts[Transition{From: e.From, To: e.To}] += e.Count
+	}
+	return nil
+}
+
+// WriteCoverage writes the coverage counts as JSON.
+func WriteCoverage(w io.Writer, c *Coverage) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// ReadCoverage reads coverage counts written by WriteCoverage.
+func ReadCoverage(r io.Reader) (*Coverage, error) {
+	c := NewCoverage()
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, fmt.Errorf("reading coverage: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,183 @@
+package statemachine
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// TestCoverageRecording verifies Execute records each transition it takes.
+func TestCoverageRecording(t *testing.T) {
+	sm := NewStateMachine[testModel, int](&testModel{0}, "test")
+	for _, s := range []*State[testModel, int]{
+		NewState(s1, fstate1, nil),
+		NewState(s2, fstate2, nil),
+		NewState(s3, fstate3, nil),
+		NewState(s4, fstate4, nil),
+	} {
+		if err := sm.AddState(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	cov := NewCoverage()
+	sm.SetCoverage(cov)
+	for i := 0; i < 5; i++ {
+		if _, err := sm.Execute(&testModel{0}, i); err != nil {
+			t.Fatalf("unexpected error during execution: %s", err)
+		}
+	}
+
+	tests := []struct {
+		from, to StateKey
+		want     int
+	}{
+		{s1, s2, 2},
+		{s2, s3, 1},
+		{s3, s4, 1},
+		{s4, s1, 1},
+		{s1, s3, 0},
+	}
+	for _, tt := range tests {
+		if got := cov.Count(tt.from, tt.to); got != tt.want {
+			t.Errorf("Count(%v, %v) = %d, want %d", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// TestCoverageStayNotRecorded verifies staying in a state is not recorded
+// as a transition.
+func TestCoverageStayNotRecorded(t *testing.T) {
+	sm := NewStateMachine[testModel, int](&testModel{0}, "test")
+	state := NewState(s1,
+		func(x *State[testModel, int], model *testModel, input int) (key StateKey, err error) {
+			return x.Key, nil
+		},
+		nil,
+	)
+	if err := sm.AddState(state); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cov := NewCoverage()
+	sm.SetCoverage(cov)
+	if _, err := sm.Execute(&testModel{0}, 1); err != nil {
+		t.Fatalf("unexpected error during execution: %s", err)
+	}
+	if counts := cov.Counts(); len(counts) != 0 {
+		t.Errorf("Counts() = %v, want none", counts)
+	}
+}
+
+// TestCoverageSelfLoopDiagram verifies staying in a state is recorded as
+// the self-transition its diagram draws.
+func TestCoverageSelfLoopDiagram(t *testing.T) {
+	g := graph.NewGraph()
+	if err := g.Load([]string{"Polling,Polling,tick", "Polling,Idle,stop"}); err != nil {
+		t.Fatalf("Load Error: %v", err)
+	}
+	var loops []StateKey
+	for _, node := range g.NodeNames() {
+		for _, e := range g.Nodes[node] {
+			if e.From == e.To {
+				loops = append(loops, StateKey(e.From))
+			}
+		}
+	}
+
+	sm := NewStateMachine[testModel, int](&testModel{0}, "test")
+	for _, s := range []*State[testModel, int]{
+		NewState("Polling", func(x *State[testModel, int], model *testModel, input int) (StateKey, error) {
+			if input == 0 {
+				return x.Key, nil
+			}
+			return "Idle", nil
+		}, nil),
+		NewState("Idle", func(x *State[testModel, int], model *testModel, input int) (StateKey, error) {
+			return x.Key, nil
+		}, nil),
+	} {
+		if err := sm.AddState(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	cov := NewCoverage()
+	cov.SetSelfLoops(loops...)
+	sm.SetCoverage(cov)
+	for _, input := range []int{0, 0, 1, 0} {
+		if _, err := sm.Execute(&testModel{0}, input); err != nil {
+			t.Fatalf("unexpected error during execution: %s", err)
+		}
+	}
+	want := map[Transition]int{
+		{From: "Polling", To: "Polling"}: 2,
+		{From: "Polling", To: "Idle"}:    1,
+	}
+	if got := cov.Counts(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Counts() = %v, want %v", got, want)
+	}
+}
+
+// TestCoverageSelfTransition verifies a loop in the event table is
+// recorded.
+func TestCoverageSelfTransition(t *testing.T) {
+	sm := NewStateMachine[testModel, Event](&testModel{0}, "test")
+	if err := sm.AddState(NewState(s1, NoAction[testModel, Event], nil)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	sm.SetEventTable(EventTable{s1: {"tick": s1}})
+
+	cov := NewCoverage()
+	sm.SetCoverage(cov)
+	if _, err := sm.Execute(&testModel{0}, "tick"); err != nil {
+		t.Fatalf("unexpected error during execution: %s", err)
+	}
+	if got := cov.Count(s1, s1); got != 1 {
+		t.Errorf("Count(%v, %v) = %d, want 1", s1, s1, got)
+	}
+}
+
+// TestCoverageMergeAndRoundTrip verifies counts survive JSON and merge by addition.
+func TestCoverageMergeAndRoundTrip(t *testing.T) {
+	a := NewCoverage()
+	a.Record(s1, s2)
+	a.Record(s1, s2)
+	b := NewCoverage()
+	b.Record(s1, s2)
+	b.Record(s2, s3)
+
+	var buf bytes.Buffer
+	if err := WriteCoverage(&buf, b); err != nil {
+		t.Fatalf("WriteCoverage() error = %v", err)
+	}
+	read, err := ReadCoverage(&buf)
+	if err != nil {
+		t.Fatalf("ReadCoverage() error = %v", err)
+	}
+
+	a.Merge(read)
+	if got := a.Count(s1, s2); got != 3 {
+		t.Errorf("Count(%v, %v) = %d, want 3", s1, s2, got)
+	}
+	if got := a.Count(s2, s3); got != 1 {
+		t.Errorf("Count(%v, %v) = %d, want 1", s2, s3, got)
+	}
+
+	// merging with itself must not double the counts
+	a.Merge(a)
+	if got := a.Count(s1, s2); got != 3 {
+		t.Errorf("Count(%v, %v) after self merge = %d, want 3", s1, s2, got)
+	}
+}
+
+// TestReadCoverageInvalid verifies malformed coverage files are rejected.
+func TestReadCoverageInvalid(t *testing.T) {
+	for _, in := range []string{"not json", `[{"from":"a","to":"b","count":-1}]`} {
+		if _, err := ReadCoverage(bytes.NewBufferString(in)); err == nil {
+			t.Errorf("ReadCoverage(%q) expected error", in)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+)
+
+// Event names a transition in event table mode. It is the description of a
+// transition in the diagram the table was generated from.
+type Event string
+
+// String returns the string representation of the event.
+func (e Event) String() string {
+	return string(e)
+}
+
+// Event returns the event itself, so Event can be used directly as the
+// Input of a machine in event table mode.
+func (e Event) Event() Event {
+	return e
+}
+
+// EventInput is implemented by inputs that carry an event. A machine in
+// event table mode requires its Input type to implement it.
+type EventInput interface {
+	Event() Event
+}
+
+// EventTable maps a state and an event to the state the event leads to.
+// Unlabelled transitions are stored under the empty event.
+type EventTable map[StateKey]map[Event]StateKey
+
+// Target returns the state that event leads to from state.
+func (t EventTable) Target(state StateKey, event Event) (StateKey, bool) {
+	next, ok := t[state][event]
+	return next, ok
+}
+
+// NoAction is an action that does nothing, for states that have no side
+// effects in event table mode. It stays in the current state.
+func NoAction[Model any, Input any](current *State[Model, Input], model *Model, input Input) (StateKey, error) {
+	return current.Key, nil
+}
+
+// SetEventTable switches the machine to event table mode. Execute then
+// reads the event from the input, looks up the next state in the table
+// and moves to it. The current state's action still runs first, as a side
+// effect: if it returns an error the machine stays where it is, or takes
+// the state's error transition, otherwise the key it returns is ignored.
+// Passing nil returns the machine to the default mode, where actions
+// choose the next state.
+func (sm *StateMachine[Model, Input]) SetEventTable(table EventTable) {
+	sm.events = table
+}
+
+// executeEvent is Execute in event table mode.
+func (sm *StateMachine[Model, Input]) executeEvent(ctx context.Context, model *Model, input Input) (StateKey, error) {
+	carrier, ok := any(input).(EventInput)
+	if !ok {
+		return "", fmt.Errorf("input %T does not carry an event", input)
+	}
+	event := carrier.Event()
+	from := sm.currentState.GetKey()
+
+	key, ok := sm.events.Target(from, event)
+	if !ok {
+		return "", fmt.Errorf("no transition for event %q in state %v", event, from)
+	}
+	newState, exists := sm.states[key]
+	if !exists {
+		return "", fmt.Errorf("state %v does not exist", key)
+	}
+
+	if _, err := sm.run(ctx, model, input); err != nil {
+		return sm.fail(err)
+	}
+
+	sm.transition(newState)
+	return key, nil
+}
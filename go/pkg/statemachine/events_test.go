@@ -0,0 +1,95 @@
+package statemachine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// orderEvents is the event table of a small order machine.
+var orderEvents = EventTable{
+	"Idle":       {"submit": "Processing"},
+	"Processing": {"accept": "Done", "reject": "Idle"},
+}
+
+// newEventMachine builds the order machine in event table mode. The Idle
+// action counts how often it runs.
+func newEventMachine(t *testing.T) *StateMachine[testModel, Event] {
+	t.Helper()
+	sm := NewStateMachine[testModel, Event](&testModel{0}, "events")
+	idle := func(current *State[testModel, Event], model *testModel, input Event) (StateKey, error) {
+		model.value++
+		return "ignored", nil
+	}
+	for _, s := range []*State[testModel, Event]{
+		NewState("Idle", idle, nil),
+		NewState("Processing", NoAction[testModel, Event], nil),
+		NewState("Done", NoAction[testModel, Event], nil),
+	} {
+		if err := sm.AddState(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	sm.SetEventTable(orderEvents)
+	return sm
+}
+
+func TestExecuteEvents(t *testing.T) {
+	sm := newEventMachine(t)
+	model := &testModel{0}
+
+	for _, step := range []struct {
+		event Event
+		want  StateKey
+	}{
+		{"submit", "Processing"},
+		{"reject", "Idle"},
+		{"submit", "Processing"},
+		{"accept", "Done"},
+	} {
+		key, err := sm.Execute(model, step.event)
+		if err != nil {
+			t.Fatalf("Execute(%q) error = %v", step.event, err)
+		}
+		if key != step.want || sm.GetCurrentState().Key != step.want {
+			t.Fatalf("Execute(%q) = %v, want %v", step.event, key, step.want)
+		}
+	}
+	if model.value != 2 {
+		t.Errorf("Idle action ran %d times, want 2", model.value)
+	}
+}
+
+func TestExecuteEventErrors(t *testing.T) {
+	sm := newEventMachine(t)
+	if _, err := sm.Execute(&testModel{0}, "accept"); err == nil || !strings.Contains(err.Error(), `no transition for event "accept" in state Idle`) {
+		t.Errorf("Execute() error = %v", err)
+	}
+	if sm.GetCurrentState().Key != "Idle" {
+		t.Errorf("machine moved to %v on an unknown event", sm.GetCurrentState().Key)
+	}
+
+	// an action error keeps the machine in its state
+	failed := errors.New("failed")
+	sm = NewStateMachine[testModel, Event](&testModel{0}, "events")
+	sm.AddState(NewState("Idle", func(*State[testModel, Event], *testModel, Event) (StateKey, error) {
+		return "", failed
+	}, nil))
+	sm.AddState(NewState("Processing", NoAction[testModel, Event], nil))
+	sm.SetEventTable(orderEvents)
+	if _, err := sm.Execute(&testModel{0}, "submit"); !errors.Is(err, failed) {
+		t.Errorf("Execute() error = %v, want %v", err, failed)
+	}
+	if sm.GetCurrentState().Key != "Idle" {
+		t.Errorf("machine moved to %v after an action error", sm.GetCurrentState().Key)
+	}
+}
+
+func TestExecuteEventInput(t *testing.T) {
+	sm := NewStateMachine[testModel, int](&testModel{0}, "events")
+	sm.AddState(NewState(s1, NoAction[testModel, int], nil))
+	sm.SetEventTable(EventTable{s1: {"go": s1}})
+	if _, err := sm.Execute(&testModel{0}, 1); err == nil || !strings.Contains(err.Error(), "does not carry an event") {
+		t.Errorf("Execute() error = %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Clock waits for retry backoff delays. Tests can replace the system
+// clock with SetClock so retries do not actually sleep.
+type Clock interface {
+	// After returns a channel that receives once the duration has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock a machine uses by default.
+type systemClock struct{}
+
+// After waits on the real time.
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// RetryPolicy describes how often a failing action is run again before
+// its error is handled, and how long to wait in between.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times the action runs, including the
+	// first; 1 or less means no retries
+	MaxAttempts int
+	// Backoff is the delay before the first retry
+	Backoff time.Duration
+	// Multiplier scales the delay after every retry; values below 1 keep
+	// it constant
+	Multiplier float64
+	// MaxBackoff caps the delay; zero means no cap
+	MaxBackoff time.Duration
+}
+
+// Delay returns the delay before the given retry, counting from 1.
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	d := float64(p.Backoff)
+	if p.Multiplier > 1 {
+		for i := 1; i < retry; i++ {
+			d *= p.Multiplier
+			if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+				break
+			}
+		}
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+// SetErrorState makes an error from state's action move the machine to
+// target, as a diagram transition such as "Processing --> Failed : error"
+// describes, instead of making Execute fail. The error is kept and can be
+// read with Err, for example by the target state's action. Errors such as
+// an unknown state or a missing event transition are returned as usual.
+func (sm *StateMachine[Model, Input]) SetErrorState(state, target StateKey) {
+	if sm.errorStates == nil {
+		sm.errorStates = make(map[StateKey]StateKey)
+	}
+	sm.errorStates[state] = target
+}
+
+// SetDefaultErrorState sets the error target of every state that has none
+// of its own. An empty target removes it.
+func (sm *StateMachine[Model, Input]) SetDefaultErrorState(target StateKey) {
+	sm.errorState = target
+}
+
+// SetRetryPolicy makes a failing action of state run again as the policy
+// describes before its error is handled.
+func (sm *StateMachine[Model, Input]) SetRetryPolicy(state StateKey, policy RetryPolicy) {
+	if sm.retries == nil {
+		sm.retries = make(map[StateKey]RetryPolicy)
+	}
+	sm.retries[state] = policy
+}
+
+// SetDefaultRetryPolicy sets the retry policy of every state that has none
+// of its own.
+func (sm *StateMachine[Model, Input]) SetDefaultRetryPolicy(policy RetryPolicy) {
+	sm.retry = policy
+}
+
+// SetClock replaces the clock used to wait between retries. Passing nil
+// restores the system clock.
+func (sm *StateMachine[Model, Input]) SetClock(c Clock) {
+	if c == nil {
+		c = systemClock{}
+	}
+	sm.clock = c
+}
+
+// Err returns the error that moved the machine into its current state by
+// an error transition, or nil if it got there by an ordinary one.
+func (sm *StateMachine[Model, Input]) Err() error {
+	return sm.err
+}
+
+// Err returns the error that moved the state's machine into its current
+// state; see StateMachine.Err.
+func (s *State[Model, Input]) Err() error {
+	if s.machine == nil {
+		return nil
+	}
+	return s.machine.Err()
+}
+
+// run performs the current state's action, retrying it as the state's
+// retry policy describes. Every attempt goes through the middleware chain.
+// If the context is done while waiting to retry, the last error is returned.
+func (sm *StateMachine[Model, Input]) run(ctx context.Context, model *Model, input Input) (StateKey, error) {
+	action := sm.currentState.action()
+	if action == nil {
+		return sm.currentState.GetKey(), nil
+	}
+	policy, ok := sm.retries[sm.currentState.GetKey()]
+	if !ok {
+		policy = sm.retry
+	}
+	action = sm.wrap(action)
+	for attempt := 1; ; attempt++ {
+		key, err := action(ctx, sm.currentState, model, input)
+		if err == nil || attempt >= policy.MaxAttempts {
+			return key, err
+		}
+		select {
+		case <-sm.clock.After(policy.Delay(attempt)):
+		case <-ctx.Done():
+			return key, err
+		}
+	}
+}
+
+// fail takes the error transition of the current state for an action
+// error, or returns the error if the state has none.
+func (sm *StateMachine[Model, Input]) fail(err error) (StateKey, error) {
+	target, ok := sm.errorStates[sm.currentState.GetKey()]
+	if !ok {
+		target = sm.errorState
+	}
+	if target == "" {
+		return "", err
+	}
+	newState, exists := sm.states[target]
+	if !exists {
+		return "", fmt.Errorf("error state %v does not exist: %w", target, err)
+	}
+	sm.transition(newState)
+	sm.err = err
+	return target, nil
+}
+
+// transition moves the machine to a new state, recording coverage and
+// stopping and starting do-activities.
+func (sm *StateMachine[Model, Input]) transition(newState *State[Model, Input]) {
+	if sm.coverage != nil {
+		sm.coverage.Record(sm.currentState.GetKey(), newState.GetKey())
+	}
+	sm.StopActivity()
+	sm.currentState = newState
+	sm.err = nil
+	sm.startActivity()
+}
@@ -0,0 +1,147 @@
+package statemachine
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClock records the delays it is asked to wait and returns at once.
+type fakeClock struct {
+	waits []time.Duration
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+	return ch
+}
+
+var errFlaky = errors.New("flaky")
+
+// newFailureMachine builds a machine whose Processing action fails until
+// it has run model.value times, then moves to Done. Failed records the
+// error that brought the machine there.
+func newFailureMachine(t *testing.T, seen *error) *StateMachine[testModel, int] {
+	t.Helper()
+	sm := NewStateMachine[testModel, int](&testModel{0}, "failure")
+	processing := func(current *State[testModel, int], model *testModel, input int) (StateKey, error) {
+		model.value--
+		if model.value > 0 {
+			return "", errFlaky
+		}
+		return "Done", nil
+	}
+	failed := func(current *State[testModel, int], model *testModel, input int) (StateKey, error) {
+		*seen = current.Err()
+		return "Processing", nil
+	}
+	for _, s := range []*State[testModel, int]{
+		NewState("Processing", processing, nil),
+		NewState("Failed", failed, nil),
+		NewState("Done", NoAction[testModel, int], nil),
+	} {
+		if err := sm.AddState(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	return sm
+}
+
+func TestExecuteErrorState(t *testing.T) {
+	var seen error
+	sm := newFailureMachine(t, &seen)
+
+	// without an error target the machine stays put
+	if _, err := sm.Execute(&testModel{2}, 0); !errors.Is(err, errFlaky) {
+		t.Fatalf("Execute() error = %v, want %v", err, errFlaky)
+	}
+	if sm.GetCurrentState().Key != "Processing" {
+		t.Fatalf("current state = %v, want Processing", sm.GetCurrentState().Key)
+	}
+
+	sm.SetErrorState("Processing", "Failed")
+	key, err := sm.Execute(&testModel{2}, 0)
+	if err != nil || key != "Failed" {
+		t.Fatalf("Execute() = %v, %v, want Failed", key, err)
+	}
+	if !errors.Is(sm.Err(), errFlaky) {
+		t.Errorf("Err() = %v, want %v", sm.Err(), errFlaky)
+	}
+
+	// the target state's action sees the error, which is cleared once
+	// the machine moves on
+	if key, err := sm.Execute(&testModel{0}, 0); err != nil || key != "Processing" {
+		t.Fatalf("Execute() = %v, %v, want Processing", key, err)
+	}
+	if !errors.Is(seen, errFlaky) {
+		t.Errorf("Failed saw %v, want %v", seen, errFlaky)
+	}
+	if sm.Err() != nil {
+		t.Errorf("Err() = %v after an ordinary transition", sm.Err())
+	}
+}
+
+func TestExecuteDefaultErrorState(t *testing.T) {
+	var seen error
+	sm := newFailureMachine(t, &seen)
+	sm.SetDefaultErrorState("Failed")
+	if key, err := sm.Execute(&testModel{2}, 0); err != nil || key != "Failed" {
+		t.Fatalf("Execute() = %v, %v, want Failed", key, err)
+	}
+
+	sm.SetDefaultErrorState("Missing")
+	sm.SetInitialState("Processing")
+	if _, err := sm.Execute(&testModel{2}, 0); !errors.Is(err, errFlaky) {
+		t.Errorf("Execute() error = %v, want it to wrap %v", err, errFlaky)
+	}
+}
+
+func TestExecuteRetry(t *testing.T) {
+	var seen error
+	sm := newFailureMachine(t, &seen)
+	clock := &fakeClock{}
+	sm.SetClock(clock)
+	sm.SetRetryPolicy("Processing", RetryPolicy{
+		MaxAttempts: 4,
+		Backoff:     time.Second,
+		Multiplier:  2,
+	})
+
+	// succeeds on the third attempt
+	model := &testModel{3}
+	if key, err := sm.Execute(model, 0); err != nil || key != "Done" {
+		t.Fatalf("Execute() = %v, %v, want Done", key, err)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second}; !reflect.DeepEqual(clock.waits, want) {
+		t.Errorf("waits = %v, want %v", clock.waits, want)
+	}
+
+	// gives up after four attempts and takes the error transition
+	sm.SetInitialState("Processing")
+	sm.SetErrorState("Processing", "Failed")
+	model = &testModel{10}
+	if key, err := sm.Execute(model, 0); err != nil || key != "Failed" {
+		t.Fatalf("Execute() = %v, %v, want Failed", key, err)
+	}
+	if model.value != 6 {
+		t.Errorf("action ran %d times, want 4", 10-model.value)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, Multiplier: 3, MaxBackoff: time.Second}
+	var got []time.Duration
+	for retry := 1; retry <= 4; retry++ {
+		got = append(got, p.Delay(retry))
+	}
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Delay() = %v, want %v", got, want)
+	}
+	if d := (RetryPolicy{Backoff: time.Second}).Delay(5); d != time.Second {
+		t.Errorf("constant Delay() = %v, want 1s", d)
+	}
+}
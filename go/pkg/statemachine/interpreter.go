@@ -0,0 +1,196 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+	parser "sqirvy.xyz/state-gen/pkg/parser"
+)
+
+// EventHandler is a side effect run when a transition with a given event
+// is taken. Returning an error keeps the machine in its current state.
+type EventHandler[Model any, Input any] func(model *Model, input Input) error
+
+// Interpreter builds a StateMachine directly from a Mermaid diagram, so the
+// diagram, for example one embedded with //go:embed, is the source of truth
+// and nothing is generated. Each state is driven either by a state handler,
+// an ActionFunc registered with OnState whose returned key must follow an
+// edge of the diagram, or by events: the input carries an Event, through
+// EventInput, that selects one of the state's transitions, and the handler
+// registered for that event with OnEvent runs as a side effect.
+type Interpreter[Model any, Input any] struct {
+	name   string
+	graph  *graph.Graph
+	states map[string]ActionFunc[Model, Input]
+	events map[string]EventHandler[Model, Input]
+	errs   []error
+}
+
+// NewInterpreter parses a diagram for a machine with the given name.
+func NewInterpreter[Model any, Input any](name string, diagram io.Reader) (*Interpreter[Model, Input], error) {
+	d, err := parser.Parse(diagram)
+	if err != nil {
+		return nil, err
+	}
+	g, _, err := graph.FromDiagram(d)
+	if err != nil {
+		return nil, err
+	}
+	return &Interpreter[Model, Input]{
+		name:   name,
+		graph:  g,
+		states: make(map[string]ActionFunc[Model, Input]),
+		events: make(map[string]EventHandler[Model, Input]),
+	}, nil
+}
+
+// Graph returns the graph parsed from the diagram.
+func (in *Interpreter[Model, Input]) Graph() *graph.Graph {
+	return in.graph
+}
+
+// OnState registers the action of a state. The action chooses the next
+// state as usual, but Execute fails if the key it returns is neither the
+// state itself nor the target of one of its edges. Registering an unknown
+// state, or a state twice, is reported by Build.
+func (in *Interpreter[Model, Input]) OnState(state string, action ActionFunc[Model, Input]) *Interpreter[Model, Input] {
+	if _, ok := in.graph.Nodes[state]; !ok {
+		in.errs = append(in.errs, fmt.Errorf("state %s is not in the diagram", state))
+	} else if _, ok := in.states[state]; ok {
+		in.errs = append(in.errs, fmt.Errorf("state %s has more than one handler", state))
+	}
+	in.states[state] = action
+	return in
+}
+
+// OnEvent registers the side effect of every transition with the given
+// event. Registering an event no transition uses, or an event twice, is
+// reported by Build.
+func (in *Interpreter[Model, Input]) OnEvent(event string, handler EventHandler[Model, Input]) *Interpreter[Model, Input] {
+	used := false
+	for _, edges := range in.graph.Nodes {
+		for _, e := range edges {
+			used = used || e.Event == event
+		}
+	}
+	if !used {
+		in.errs = append(in.errs, fmt.Errorf("event %q is not in the diagram", event))
+	} else if _, ok := in.events[event]; ok {
+		in.errs = append(in.errs, fmt.Errorf("event %q has more than one handler", event))
+	}
+	in.events[event] = handler
+	return in
+}
+
+// Build creates the state machine and starts it in START, or in the first
+// state in sorted order if the diagram has no [*] transition. END is the
+// final state. Every state
+// other than START and END must have a state handler, or a handler for
+// each event on its transitions; a state driven by events must not have
+// two transitions with the same event to different states. All problems,
+// including those found while registering handlers, are returned together.
+func (in *Interpreter[Model, Input]) Build(model *Model) (*StateMachine[Model, Input], error) {
+	errs := append([]error{}, in.errs...)
+	actions := make(map[string]ActionFunc[Model, Input])
+	for _, node := range in.graph.NodeNames() {
+		if action, ok := in.states[node]; ok {
+			actions[node] = in.checked(node, action)
+			continue
+		}
+		action, err := in.eventDriven(node)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		actions[node] = action
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	sm := NewStateMachine[Model, Input](model, in.name)
+	for _, node := range in.graph.NodeNames() {
+		if err := sm.AddState(NewState(StateKey(node), actions[node], nil)); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := in.graph.Nodes["START"]; ok {
+		if err := sm.SetInitialState("START"); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := in.graph.Nodes["END"]; ok {
+		if err := sm.SetFinalStates("END"); err != nil {
+			return nil, err
+		}
+	}
+	return sm, nil
+}
+
+// checked wraps a state handler so it can only follow the state's edges.
+func (in *Interpreter[Model, Input]) checked(node string, action ActionFunc[Model, Input]) ActionFunc[Model, Input] {
+	allowed := map[StateKey]bool{StateKey(node): true}
+	for _, e := range in.graph.Nodes[node] {
+		allowed[StateKey(e.To)] = true
+	}
+	return func(current *State[Model, Input], model *Model, input Input) (StateKey, error) {
+		key, err := action(current, model, input)
+		if err != nil {
+			return key, err
+		}
+		if !allowed[key] {
+			return "", fmt.Errorf("transition %s -> %s is not in the diagram", node, key)
+		}
+		return key, nil
+	}
+}
+
+// eventDriven returns the action of a state without a state handler. The
+// input's event picks the transition; unlabelled transitions are taken on
+// the empty event and need no handler.
+func (in *Interpreter[Model, Input]) eventDriven(node string) (ActionFunc[Model, Input], error) {
+	edges := in.graph.Nodes[node]
+	if len(edges) == 0 {
+		if node == "END" {
+			return NoAction[Model, Input], nil
+		}
+		return nil, fmt.Errorf("state %s has no handler", node)
+	}
+
+	targets := make(map[string]string)
+	for _, e := range edges {
+		if node != "START" && e.Event == "" {
+			return nil, fmt.Errorf("state %s has no handler and its transition to %s has no event", node, e.To)
+		}
+		if e.Event != "" {
+			if _, ok := in.events[e.Event]; !ok {
+				return nil, fmt.Errorf("state %s has no handler and event %q has none either", node, e.Event)
+			}
+		}
+		if to, ok := targets[e.Event]; ok && to != e.To {
+			return nil, fmt.Errorf("state %s: event %q leads to %s and %s", node, e.Event, to, e.To)
+		}
+		targets[e.Event] = e.To
+	}
+
+	return func(current *State[Model, Input], model *Model, input Input) (StateKey, error) {
+		event := ""
+		if carrier, ok := any(input).(EventInput); ok {
+			event = string(carrier.Event())
+		} else if _, unlabelled := targets[""]; !unlabelled {
+			return "", fmt.Errorf("input %T does not carry an event", input)
+		}
+		to, ok := targets[event]
+		if !ok {
+			return "", fmt.Errorf("no transition for event %q in state %s", event, node)
+		}
+		if handler := in.events[event]; handler != nil {
+			if err := handler(model, input); err != nil {
+				return "", err
+			}
+		}
+		return StateKey(to), nil
+	}, nil
+}
@@ -0,0 +1,135 @@
+package statemachine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// orderDiagram is the order machine, as it would be embedded with go:embed.
+const orderDiagram = "```mermaid\n" +
+	"stateDiagram-v2\n" +
+	"[*] --> Idle\n" +
+	"Idle --> Processing : submit\n" +
+	"Processing --> Idle : reject\n" +
+	"Processing --> Done : accept\n" +
+	"Done --> [*]\n" +
+	"```\n"
+
+func newOrderInterpreter(t *testing.T) *Interpreter[testModel, Event] {
+	t.Helper()
+	in, err := NewInterpreter[testModel, Event]("order", strings.NewReader(orderDiagram))
+	if err != nil {
+		t.Fatalf("NewInterpreter() error = %v", err)
+	}
+	return in
+}
+
+func TestInterpreter(t *testing.T) {
+	model := &testModel{0}
+	sm, err := newOrderInterpreter(t).
+		OnEvent("submit", func(model *testModel, input Event) error {
+			model.value++
+			return nil
+		}).
+		OnEvent("reject", func(*testModel, Event) error { return nil }).
+		OnEvent("accept", func(*testModel, Event) error { return nil }).
+		OnState("Done", func(current *State[testModel, Event], model *testModel, input Event) (StateKey, error) {
+			return "END", nil
+		}).
+		Build(model)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	for _, step := range []struct {
+		event Event
+		want  StateKey
+	}{
+		{"", "Idle"},
+		{"submit", "Processing"},
+		{"reject", "Idle"},
+		{"submit", "Processing"},
+		{"accept", "Done"},
+		{"", "END"},
+	} {
+		key, err := sm.Execute(model, step.event)
+		if err != nil {
+			t.Fatalf("Execute(%q) error = %v", step.event, err)
+		}
+		if key != step.want {
+			t.Fatalf("Execute(%q) = %v, want %v", step.event, key, step.want)
+		}
+	}
+	if model.value != 2 {
+		t.Errorf("submit handler ran %d times, want 2", model.value)
+	}
+	if !sm.Done() {
+		t.Error("machine in END is not done")
+	}
+}
+
+func TestInterpreterEnforcesEdges(t *testing.T) {
+	model := &testModel{0}
+	sm, err := newOrderInterpreter(t).
+		OnState("Idle", func(current *State[testModel, Event], model *testModel, input Event) (StateKey, error) {
+			return "Done", nil
+		}).
+		OnState("Processing", NoAction[testModel, Event]).
+		OnState("Done", NoAction[testModel, Event]).
+		Build(model)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if _, err := sm.Execute(model, ""); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	_, err = sm.Execute(model, "")
+	if err == nil || !strings.Contains(err.Error(), "transition Idle -> Done is not in the diagram") {
+		t.Errorf("Execute() error = %v", err)
+	}
+	if sm.GetCurrentState().Key != "Idle" {
+		t.Errorf("machine moved to %v", sm.GetCurrentState().Key)
+	}
+}
+
+func TestInterpreterEventErrors(t *testing.T) {
+	failed := errors.New("failed")
+	model := &testModel{0}
+	sm, err := newOrderInterpreter(t).
+		OnEvent("submit", func(*testModel, Event) error { return failed }).
+		OnState("Processing", NoAction[testModel, Event]).
+		OnState("Done", NoAction[testModel, Event]).
+		Build(model)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	sm.Execute(model, "")
+	if _, err := sm.Execute(model, "submit"); !errors.Is(err, failed) {
+		t.Errorf("Execute() error = %v, want %v", err, failed)
+	}
+	if _, err := sm.Execute(model, "accept"); err == nil || !strings.Contains(err.Error(), `no transition for event "accept" in state Idle`) {
+		t.Errorf("Execute() error = %v", err)
+	}
+}
+
+func TestInterpreterMissingHandlers(t *testing.T) {
+	_, err := newOrderInterpreter(t).
+		OnEvent("submit", func(*testModel, Event) error { return nil }).
+		OnEvent("cancel", func(*testModel, Event) error { return nil }).
+		OnState("Shipped", NoAction[testModel, Event]).
+		Build(&testModel{0})
+	if err == nil {
+		t.Fatal("Build() should fail")
+	}
+	for _, want := range []string{
+		`event "cancel" is not in the diagram`,
+		"state Shipped is not in the diagram",
+		`state Processing has no handler and event "reject" has none either`,
+		"state Done has no handler and its transition to END has no event",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Build() error missing %q:\n%v", want, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package statemachine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFinished is matched by errors.Is for the *FinishedError returned by
+// Execute once a machine has finished.
+var ErrFinished = errors.New("state machine has finished")
+
+// FinishedError is returned by Execute when the machine is in a final
+// state or has been stopped, so it accepts no more input.
+type FinishedError struct {
+	// Machine is the name of the machine
+	Machine string
+	// State is the state the machine finished in
+	State StateKey
+	// Stopped is true if the machine was stopped rather than reaching a
+	// final state
+	Stopped bool
+}
+
+// Error describes how the machine finished.
+func (e *FinishedError) Error() string {
+	if e.Stopped {
+		return fmt.Sprintf("state machine %s was stopped in state %v", e.Machine, e.State)
+	}
+	return fmt.Sprintf("state machine %s has finished in final state %v", e.Machine, e.State)
+}
+
+// Is reports whether target is ErrFinished.
+func (e *FinishedError) Is(target error) bool {
+	return target == ErrFinished
+}
+
+// SetFinalStates marks states as final. Once Execute moves the machine
+// into a final state it stops there: Done reports true, inputs still
+// queued are discarded, no do-activity is started and further calls to
+// Execute fail with a *FinishedError.
+func (sm *StateMachine[Model, Input]) SetFinalStates(keys ...StateKey) error {
+	for _, key := range keys {
+		if _, exists := sm.states[key]; !exists {
+			return fmt.Errorf("state %v does not exist", key)
+		}
+	}
+	if sm.final == nil {
+		sm.final = make(map[StateKey]bool)
+	}
+	for _, key := range keys {
+		sm.final[key] = true
+	}
+	return nil
+}
+
+// IsFinal reports whether the state with the given key is final.
+func (sm *StateMachine[Model, Input]) IsFinal(key StateKey) bool {
+	return sm.final[key]
+}
+
+// Done reports whether the machine has reached a final state or been
+// stopped.
+func (sm *StateMachine[Model, Input]) Done() bool {
+	return sm.stopped || (sm.currentState != nil && sm.final[sm.currentState.GetKey()])
+}
+
+// Stop terminates the machine: the current state's do-activity is
+// stopped, queued inputs are discarded and further calls to Execute fail
+// with a *FinishedError until Reset is called.
+func (sm *StateMachine[Model, Input]) Stop() {
+	sm.StopActivity()
+	sm.queue.clear()
+	sm.stopped = true
+}
+
+// Reset returns the machine to its initial state, the one last set with
+// SetInitialState or the first state added, and clears its history: the
+// queued inputs, the error kept by an error transition and whether it was
+// stopped. A Coverage attached with SetCoverage is kept, so coverage can
+// be collected over several runs.
+func (sm *StateMachine[Model, Input]) Reset() error {
+	if sm.initial == "" {
+		return fmt.Errorf("no initial state set")
+	}
+	sm.queue.clear()
+	sm.err = nil
+	sm.stopped = false
+	return sm.SetInitialState(sm.initial)
+}
+
+// finished returns the error for an input the machine refuses, or nil if
+// it accepts input.
+func (sm *StateMachine[Model, Input]) finished() error {
+	if !sm.Done() {
+		return nil
+	}
+	return &FinishedError{Machine: sm.name, State: sm.currentState.GetKey(), Stopped: sm.stopped}
+}
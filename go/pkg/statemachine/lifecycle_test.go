@@ -0,0 +1,88 @@
+package statemachine
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFinalStates(t *testing.T) {
+	sm := newQueueMachine(t)
+	if err := sm.SetFinalStates("C", "D"); err == nil {
+		t.Error("SetFinalStates() with an unknown state should fail")
+	}
+	if err := sm.SetFinalStates("B"); err != nil {
+		t.Fatalf("SetFinalStates() error = %v", err)
+	}
+	if !sm.IsFinal("B") || sm.IsFinal("A") {
+		t.Error("IsFinal() does not match SetFinalStates")
+	}
+
+	// B posts C on entry, which is discarded as B is final
+	key, err := sm.Execute(&testModel{0}, "chain")
+	if err != nil || key != "B" {
+		t.Fatalf("Execute() = %v, %v, want B", key, err)
+	}
+	if !sm.Done() || sm.queue.len() != 0 {
+		t.Errorf("Done() = %v with %d inputs queued", sm.Done(), sm.queue.len())
+	}
+
+	_, err = sm.Execute(&testModel{0}, "A")
+	var finished *FinishedError
+	if !errors.As(err, &finished) || !errors.Is(err, ErrFinished) {
+		t.Fatalf("Execute() error = %v, want FinishedError", err)
+	}
+	if finished.State != "B" || finished.Stopped {
+		t.Errorf("FinishedError = %+v", finished)
+	}
+	if want := "state machine queue has finished in final state B"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+}
+
+func TestStopAndReset(t *testing.T) {
+	sm := newActivityMachine(t)
+	coverage := NewCoverage()
+	sm.SetCoverage(coverage)
+	cancelled := make(chan struct{})
+	sm.SetActivity("Polling", Activity[string]{
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			close(cancelled)
+			return nil
+		},
+	})
+	if _, err := sm.Execute(&testModel{0}, "Polling"); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	sm.Post("Ready")
+
+	sm.Stop()
+	<-cancelled
+	if !sm.Done() || sm.queue.len() != 0 {
+		t.Errorf("Done() = %v with %d inputs queued", sm.Done(), sm.queue.len())
+	}
+	_, err := sm.Execute(&testModel{0}, "Ready")
+	var finished *FinishedError
+	if !errors.As(err, &finished) || !finished.Stopped || finished.State != "Polling" {
+		t.Fatalf("Execute() error = %v, want a stopped FinishedError", err)
+	}
+
+	if err := sm.Reset(); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if sm.Done() || sm.GetCurrentState().Key != "Idle" {
+		t.Errorf("after Reset: Done() = %v in %v", sm.Done(), sm.GetCurrentState().Key)
+	}
+	if key, err := sm.Execute(&testModel{0}, "Ready"); err != nil || key != "Ready" {
+		t.Errorf("Execute() = %v, %v, want Ready", key, err)
+	}
+	if coverage.Count("Idle", "Polling") != 1 {
+		t.Error("Reset cleared the coverage")
+	}
+
+	empty := NewStateMachine[testModel, string](&testModel{0}, "empty")
+	if err := empty.Reset(); err == nil {
+		t.Error("Reset() without an initial state should fail")
+	}
+}
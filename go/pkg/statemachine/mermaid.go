@@ -0,0 +1,100 @@
+package statemachine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// mermaidIDPattern matches state keys Mermaid accepts as bare identifiers.
+var mermaidIDPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Mermaid renders the state machine as a stateDiagram-v2. The optional
+// edges describe the transitions between states, since the machine itself
+// only knows them at run time; graph.FromDiagram loads them from a
+// diagram. Registered states that no edge mentions are declared on their
+// own, and the current state is highlighted with the "current" class.
+// START and END are drawn as [*], which Mermaid cannot style, so when the
+// machine is in one of them a %% comment names it instead.
+func (sm *StateMachine[Model, Input]) Mermaid(edges ...graph.Edge) string {
+	keys := make([]string, 0, len(sm.states))
+	for k := range sm.states {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+
+	// states whose keys are not valid Mermaid identifiers get an alias
+	// that no key uses
+	taken := make(map[string]bool)
+	for _, k := range keys {
+		taken[k] = true
+	}
+	for _, e := range edges {
+		taken[e.From] = true
+		taken[e.To] = true
+	}
+	ids := make(map[string]string)
+	alias := func(key string) string {
+		if key == "START" || key == "END" || mermaidIDPattern.MatchString(key) {
+			return key
+		}
+		if id, ok := ids[key]; ok {
+			return id
+		}
+		id := ""
+		for n := len(ids) + 1; id == "" || taken[id]; n++ {
+			id = fmt.Sprintf("s%d", n)
+		}
+		taken[id] = true
+		ids[key] = id
+		return id
+	}
+
+	var body strings.Builder
+	mentioned := make(map[string]bool)
+	for _, e := range edges {
+		mentioned[e.From] = true
+		mentioned[e.To] = true
+		e.From = alias(e.From)
+		e.To = alias(e.To)
+		body.WriteString("    " + graph.MermaidTransition(e) + "\n")
+	}
+	for _, k := range keys {
+		if !mentioned[k] && k != "START" && k != "END" {
+			body.WriteString("    " + alias(k) + "\n")
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("stateDiagram-v2\n")
+	sb.WriteString("    classDef current fill:#f9a825,stroke:#333,stroke-width:3px\n")
+
+	aliased := make([]string, 0, len(ids))
+	for key := range ids {
+		aliased = append(aliased, key)
+	}
+	sort.Strings(aliased)
+	for _, key := range aliased {
+		fmt.Fprintf(&sb, "    state \"%s\" as %s\n", mermaidLabel(key), ids[key])
+	}
+	sb.WriteString(body.String())
+
+	if sm.currentState != nil {
+		key := string(sm.currentState.Key)
+		if key == "START" || key == "END" {
+			fmt.Fprintf(&sb, "    %%%% current state: %s\n", key)
+		} else {
+			fmt.Fprintf(&sb, "    class %s current\n", alias(key))
+		}
+	}
+	return sb.String()
+}
+
+// mermaidLabel escapes text for a quoted Mermaid label. Mermaid has no
+// backslash escapes; characters are written as entity codes instead.
+func mermaidLabel(text string) string {
+	return strings.NewReplacer("#", "#35;", `"`, "#quot;").Replace(text)
+}
@@ -0,0 +1,131 @@
+package statemachine
+
+import (
+	"strings"
+	"testing"
+
+	graph "sqirvy.xyz/state-gen/pkg/graph"
+)
+
+// newMermaidMachine builds a machine with states 1..4 from execute_test.go.
+func newMermaidMachine(t *testing.T) *StateMachine[testModel, int] {
+	t.Helper()
+	sm := NewStateMachine[testModel, int](&testModel{0}, "test")
+	for _, s := range []*State[testModel, int]{
+		NewState(s1, fstate1, nil),
+		NewState(s2, fstate2, nil),
+		NewState(s3, fstate3, nil),
+		NewState(s4, fstate4, nil),
+	} {
+		if err := sm.AddState(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	return sm
+}
+
+func TestMermaidStatesOnly(t *testing.T) {
+	sm := newMermaidMachine(t)
+	if _, err := sm.Execute(&testModel{0}, 1); err != nil {
+		t.Fatalf("unexpected error during execution: %s", err)
+	}
+
+	want := `stateDiagram-v2
+    classDef current fill:#f9a825,stroke:#333,stroke-width:3px
+    state1
+    state2
+    state3
+    state4
+    class state2 current
+`
+	if got := sm.Mermaid(); got != want {
+		t.Errorf("Mermaid() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMermaidWithEdges(t *testing.T) {
+	sm := newMermaidMachine(t)
+	got := sm.Mermaid(
+		graph.Edge{From: "START", To: "state1", Description: "-"},
+		graph.Edge{From: "state1", To: "state2", Description: "go"},
+	)
+
+	for _, want := range []string{
+		"    [*] --> state1\n",
+		"    state1 --> state2 : go\n",
+		"    state3\n",
+		"    state4\n",
+		"    class state1 current\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Mermaid() missing %q\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "    state1\n") {
+		t.Errorf("Mermaid() declared state1 twice\n%s", got)
+	}
+}
+
+func TestMermaidAliasesKeys(t *testing.T) {
+	sm := NewStateMachine[testModel, int](&testModel{0}, "test")
+	state := NewState("wait for input",
+		func(x *State[testModel, int], model *testModel, input int) (key StateKey, err error) {
+			return x.Key, nil
+		},
+		nil,
+	)
+	if err := sm.AddState(state); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got := sm.Mermaid()
+	for _, want := range []string{
+		"    state \"wait for input\" as s1\n",
+		"    s1\n",
+		"    class s1 current\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Mermaid() missing %q\n%s", want, got)
+		}
+	}
+}
+
+func TestMermaidAliasesAvoidKeys(t *testing.T) {
+	sm := NewStateMachine[testModel, int](&testModel{0}, "test")
+	for _, key := range []StateKey{"s1", `say "hi" #1\n`} {
+		if err := sm.AddState(NewState(key, fstate1, nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	got := sm.Mermaid()
+	for _, want := range []string{
+		"    state \"say #quot;hi#quot; #35;1\\n\" as s2\n",
+		"    s1\n",
+		"    s2\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Mermaid() missing %q\n%s", want, got)
+		}
+	}
+}
+
+func TestMermaidCurrentStart(t *testing.T) {
+	sm := NewStateMachine[testModel, int](&testModel{0}, "test")
+	for _, s := range []*State[testModel, int]{
+		NewState("START", fstate4, nil),
+		NewState(s1, fstate1, nil),
+	} {
+		if err := sm.AddState(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	got := sm.Mermaid(graph.Edge{From: "START", To: "state1", Description: "-"})
+	if !strings.Contains(got, "    %% current state: START\n") {
+		t.Errorf("Mermaid() does not name the current state\n%s", got)
+	}
+	if strings.Contains(got, "    class ") {
+		t.Errorf("Mermaid() styles a pseudo-state\n%s", got)
+	}
+}
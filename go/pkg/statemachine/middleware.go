@@ -0,0 +1,109 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps the invocation of state actions, like HTTP middleware
+// wraps handlers. It receives the next action in the chain and returns an
+// action that usually calls it, with access to the context, current
+// state, model and input before and after.
+type Middleware[Model any, Input any] func(next ContextActionFunc[Model, Input]) ContextActionFunc[Model, Input]
+
+// Use appends middleware to the machine's chain. Every action Execute
+// runs, including retries and the actions of completion transitions, is
+// wrapped by the chain; the first middleware added is the outermost.
+func (sm *StateMachine[Model, Input]) Use(middleware ...Middleware[Model, Input]) {
+	sm.middleware = append(sm.middleware, middleware...)
+}
+
+// wrap applies the middleware chain to an action.
+func (sm *StateMachine[Model, Input]) wrap(action ContextActionFunc[Model, Input]) ContextActionFunc[Model, Input] {
+	for i := len(sm.middleware) - 1; i >= 0; i-- {
+		action = sm.middleware[i](action)
+	}
+	return action
+}
+
+// PanicError is the error Recover returns for an action that panicked.
+type PanicError struct {
+	// State is the state whose action panicked
+	State StateKey
+	// Value is the value passed to panic
+	Value any
+	// Stack is the stack trace of the panic
+	Stack []byte
+}
+
+// Error describes the panic.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("action of state %v panicked: %v", e.State, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recover returns middleware that turns a panic in an action into a
+// *PanicError, so the machine stays in its state, or takes its error
+// transition, instead of crashing.
+func Recover[Model any, Input any]() Middleware[Model, Input] {
+	return func(next ContextActionFunc[Model, Input]) ContextActionFunc[Model, Input] {
+		return func(ctx context.Context, current *State[Model, Input], model *Model, input Input) (key StateKey, err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					key, err = "", &PanicError{State: current.Key, Value: v, Stack: debug.Stack()}
+				}
+			}()
+			return next(ctx, current, model, input)
+		}
+	}
+}
+
+// Timing returns middleware that reports how long each action took, and
+// the error it returned, to record.
+func Timing[Model any, Input any](record func(state StateKey, elapsed time.Duration, err error)) Middleware[Model, Input] {
+	return func(next ContextActionFunc[Model, Input]) ContextActionFunc[Model, Input] {
+		return func(ctx context.Context, current *State[Model, Input], model *Model, input Input) (StateKey, error) {
+			start := time.Now()
+			key, err := next(ctx, current, model, input)
+			record(current.Key, time.Since(start), err)
+			return key, err
+		}
+	}
+}
+
+// Logging returns middleware that logs every action to logger: at debug
+// level with the key it returned when it succeeds, at error level with
+// the error when it fails. Both records include the machine, the state
+// and how long the action took. A nil logger uses slog.Default.
+func Logging[Model any, Input any](logger *slog.Logger) Middleware[Model, Input] {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next ContextActionFunc[Model, Input]) ContextActionFunc[Model, Input] {
+		return func(ctx context.Context, current *State[Model, Input], model *Model, input Input) (StateKey, error) {
+			start := time.Now()
+			key, err := next(ctx, current, model, input)
+			attrs := []any{
+				slog.String("state", current.Key.String()),
+				slog.Duration("elapsed", time.Since(start)),
+			}
+			if current.machine != nil {
+				attrs = append([]any{slog.String("machine", current.machine.name)}, attrs...)
+			}
+			if err != nil {
+				logger.ErrorContext(ctx, "action failed", append(attrs, slog.Any("error", err))...)
+			} else {
+				logger.DebugContext(ctx, "action", append(attrs, slog.String("next", key.String()))...)
+			}
+			return key, err
+		}
+	}
+}
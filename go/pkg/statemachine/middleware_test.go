@@ -0,0 +1,121 @@
+package statemachine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMiddlewareMachine builds a machine whose A action moves to the state
+// named by the input, fails on "fail" and panics on "panic".
+func newMiddlewareMachine(t *testing.T) *StateMachine[testModel, string] {
+	t.Helper()
+	sm := NewStateMachine[testModel, string](&testModel{0}, "middleware")
+	action := func(current *State[testModel, string], model *testModel, input string) (StateKey, error) {
+		switch input {
+		case "fail":
+			return "", errFlaky
+		case "panic":
+			panic(errFlaky)
+		}
+		return StateKey(input), nil
+	}
+	for _, key := range []StateKey{"A", "B"} {
+		if err := sm.AddState(NewState(key, action, nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	return sm
+}
+
+func TestUseOrder(t *testing.T) {
+	sm := newMiddlewareMachine(t)
+	var calls []string
+	trace := func(name string) Middleware[testModel, string] {
+		return func(next ContextActionFunc[testModel, string]) ContextActionFunc[testModel, string] {
+			return func(ctx context.Context, current *State[testModel, string], model *testModel, input string) (StateKey, error) {
+				calls = append(calls, name+" "+current.Key.String())
+				key, err := next(ctx, current, model, input)
+				calls = append(calls, name+" done")
+				return key, err
+			}
+		}
+	}
+	sm.Use(trace("outer"), trace("inner"))
+
+	if key, err := sm.Execute(&testModel{0}, "B"); err != nil || key != "B" {
+		t.Fatalf("Execute() = %v, %v, want B", key, err)
+	}
+	want := []string{"outer A", "inner A", "inner done", "outer done"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	sm := newMiddlewareMachine(t)
+	sm.Use(Recover[testModel, string]())
+
+	_, err := sm.Execute(&testModel{0}, "panic")
+	var panicked *PanicError
+	if !errors.As(err, &panicked) {
+		t.Fatalf("Execute() error = %v, want PanicError", err)
+	}
+	if panicked.State != "A" || len(panicked.Stack) == 0 || !errors.Is(err, errFlaky) {
+		t.Errorf("PanicError = %+v", panicked)
+	}
+	if sm.GetCurrentState().Key != "A" {
+		t.Errorf("current state = %v, want A", sm.GetCurrentState().Key)
+	}
+}
+
+func TestTiming(t *testing.T) {
+	sm := newMiddlewareMachine(t)
+	var states []StateKey
+	var errs []error
+	sm.Use(Timing[testModel, string](func(state StateKey, elapsed time.Duration, err error) {
+		if elapsed < 0 {
+			t.Errorf("elapsed = %v", elapsed)
+		}
+		states = append(states, state)
+		errs = append(errs, err)
+	}))
+	sm.Execute(&testModel{0}, "B")
+	sm.Execute(&testModel{0}, "fail")
+
+	if want := []StateKey{"A", "B"}; !reflect.DeepEqual(states, want) {
+		t.Errorf("states = %v, want %v", states, want)
+	}
+	if errs[0] != nil || !errors.Is(errs[1], errFlaky) {
+		t.Errorf("errors = %v", errs)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	sm := newMiddlewareMachine(t)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	sm.Use(Logging[testModel, string](logger))
+	sm.Execute(&testModel{0}, "B")
+	sm.Execute(&testModel{0}, "fail")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	for i, want := range [][]string{
+		{"level=DEBUG", `msg=action`, "machine=middleware", "state=A", "elapsed=", "next=B"},
+		{"level=ERROR", `msg="action failed"`, "machine=middleware", "state=B", "error=flaky"},
+	} {
+		for _, w := range want {
+			if !strings.Contains(lines[i], w) {
+				t.Errorf("line %d = %q, missing %q", i+1, lines[i], w)
+			}
+		}
+	}
+}
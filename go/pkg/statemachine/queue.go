@@ -0,0 +1,142 @@
+package statemachine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultQueueLimit is the number of posted events a single Execute
+// processes before it gives up, unless changed with SetQueueLimit.
+const DefaultQueueLimit = 1000
+
+// queueTraceLength is the number of transitions a QueueLimitError keeps.
+const queueTraceLength = 10
+
+// QueueLimitError is returned by Execute when the actions keep posting
+// events, so the machine never reaches a state where the queue is empty.
+type QueueLimitError struct {
+	// Limit is the number of posted events that were processed
+	Limit int
+	// State is the state the machine was in when it stopped
+	State StateKey
+	// Pending is the number of events still queued, which were discarded
+	Pending int
+	// Trace lists the last transitions taken, oldest first
+	Trace []Transition
+}
+
+// Error describes where the machine was looping.
+func (e *QueueLimitError) Error() string {
+	steps := make([]string, len(e.Trace))
+	for i, t := range e.Trace {
+		steps[i] = fmt.Sprintf("%s -> %s", t.From, t.To)
+	}
+	return fmt.Sprintf("event queue limit of %d reached in state %s with %d events pending; last transitions: %s",
+		e.Limit, e.State, e.Pending, strings.Join(steps, ", "))
+}
+
+// Post queues an input, typically an event raised by the state's action,
+// on the state's machine. Execute processes queued inputs in order after
+// the input it was called with, before returning, so a chain of follow-up
+// events runs to completion without waiting for the next external input.
+// An input posted outside Execute is processed by the next Execute after
+// its own input.
+func (s *State[Model, Input]) Post(input Input) error {
+	if s.machine == nil {
+		return fmt.Errorf("state %v has not been added to a state machine", s.Key)
+	}
+	s.machine.Post(input)
+	return nil
+}
+
+// Post queues an input to be processed before the current or next
+// Execute returns; see State.Post. It is safe to call from other
+// goroutines, such as do-activities.
+func (sm *StateMachine[Model, Input]) Post(input Input) {
+	sm.queue.push(input)
+}
+
+// inputQueue holds the inputs posted to a machine. Its methods are safe
+// for concurrent use.
+type inputQueue[Input any] struct {
+	mu    sync.Mutex
+	items []Input
+}
+
+// push appends an input.
+func (q *inputQueue[Input]) push(input Input) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, input)
+}
+
+// pop removes and returns the first input.
+func (q *inputQueue[Input]) pop() (input Input, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return input, false
+	}
+	input = q.items[0]
+	q.items = q.items[1:]
+	return input, true
+}
+
+// len returns the number of queued inputs.
+func (q *inputQueue[Input]) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// clear discards the queued inputs.
+func (q *inputQueue[Input]) clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = nil
+}
+
+// SetQueueLimit sets how many posted inputs a single Execute processes
+// before it fails with a QueueLimitError, guarding against actions that
+// post events forever. A limit of zero or less means no limit.
+func (sm *StateMachine[Model, Input]) SetQueueLimit(limit int) {
+	sm.queueLimit = limit
+}
+
+// drain processes the queued inputs. key is the state the machine reached
+// with the external input and is returned if the queue is empty.
+func (sm *StateMachine[Model, Input]) drain(ctx context.Context, model *Model, key StateKey) (StateKey, error) {
+	var trace []Transition
+	for steps := 0; sm.queue.len() > 0; steps++ {
+		if sm.Done() {
+			// a final state accepts no more input
+			sm.queue.clear()
+			break
+		}
+		if sm.queueLimit > 0 && steps == sm.queueLimit {
+			return "", &QueueLimitError{
+				Limit:   sm.queueLimit,
+				State:   sm.currentState.GetKey(),
+				Pending: sm.queue.len(),
+				Trace:   trace,
+			}
+		}
+		input, ok := sm.queue.pop()
+		if !ok {
+			break
+		}
+
+		from := sm.currentState.GetKey()
+		var err error
+		if key, err = sm.step(ctx, model, input); err != nil {
+			return "", err
+		}
+		trace = append(trace, Transition{From: from, To: key})
+		if len(trace) > queueTraceLength {
+			trace = trace[1:]
+		}
+	}
+	return key, nil
+}
@@ -0,0 +1,97 @@
+package statemachine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newQueueMachine builds a machine whose states are driven by string
+// inputs naming the next state. On entry with "chain", A posts "B" and B
+// posts "C", so one Execute runs A -> B -> C.
+func newQueueMachine(t *testing.T) *StateMachine[testModel, string] {
+	t.Helper()
+	sm := NewStateMachine[testModel, string](&testModel{0}, "queue")
+	move := func(current *State[testModel, string], model *testModel, input string) (StateKey, error) {
+		model.value++
+		switch {
+		case input == "chain" && current.Key == "A":
+			if err := current.Post("B"); err != nil {
+				return "", err
+			}
+			return "A", nil
+		case input == "B":
+			current.Post("C")
+			return "B", nil
+		case input == "fail":
+			return "", errors.New("failed")
+		case input == "loop":
+			current.Post("loop")
+			return current.Key, nil
+		}
+		return StateKey(input), nil
+	}
+	for _, key := range []StateKey{"A", "B", "C"} {
+		if err := sm.AddState(NewState(key, move, nil)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	return sm
+}
+
+func TestExecuteDrainsQueue(t *testing.T) {
+	sm := newQueueMachine(t)
+	model := &testModel{0}
+	key, err := sm.Execute(model, "chain")
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if key != "C" || sm.GetCurrentState().Key != "C" {
+		t.Errorf("Execute() = %v, want C", key)
+	}
+	if model.value != 3 {
+		t.Errorf("actions ran %d times, want 3", model.value)
+	}
+}
+
+func TestExecuteQueueError(t *testing.T) {
+	sm := newQueueMachine(t)
+	sm.Post("fail")
+	sm.Post("C")
+	if _, err := sm.Execute(&testModel{0}, "B"); err == nil || err.Error() != "failed" {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	// the rest of the queue was discarded
+	key, err := sm.Execute(&testModel{0}, "A")
+	if err != nil || key != "A" {
+		t.Errorf("Execute() = %v, %v, want A", key, err)
+	}
+}
+
+func TestExecuteQueueLimit(t *testing.T) {
+	sm := newQueueMachine(t)
+	sm.SetQueueLimit(20)
+	_, err := sm.Execute(&testModel{0}, "loop")
+
+	var limit *QueueLimitError
+	if !errors.As(err, &limit) {
+		t.Fatalf("Execute() error = %v, want QueueLimitError", err)
+	}
+	if limit.Limit != 20 || limit.State != "A" || limit.Pending != 1 || len(limit.Trace) != queueTraceLength {
+		t.Errorf("QueueLimitError = %+v", limit)
+	}
+	if !reflect.DeepEqual(limit.Trace[0], Transition{From: "A", To: "A"}) {
+		t.Errorf("Trace[0] = %v", limit.Trace[0])
+	}
+	if !strings.Contains(err.Error(), "event queue limit of 20 reached in state A with 1 events pending; last transitions: A -> A") {
+		t.Errorf("Error() = %q", err)
+	}
+}
+
+func TestPostWithoutMachine(t *testing.T) {
+	state := NewState[testModel, string]("A", NoAction[testModel, string], nil)
+	if err := state.Post("B"); err == nil {
+		t.Error("Post() on a state outside a machine should fail")
+	}
+}
@@ -2,7 +2,9 @@
 package statemachine
 
 import (
+	"context"
 	"fmt"
+	"sync"
 )
 
 // StateKey represents a unique key for a state in the state machine.
@@ -19,10 +21,15 @@ func (k StateKey) String() string {
 }
 
 // State represents a state in the state machine with a key, name, and action.
+// ContextAction, if set, is used instead of Action.
 type State[Model any, Input any] struct {
-	Key    StateKey
-	Action ActionFunc[Model, Input]
-	Data   *interface{}
+	Key           StateKey
+	Action        ActionFunc[Model, Input]
+	ContextAction ContextActionFunc[Model, Input]
+	Data          *interface{}
+
+	// machine is the state machine the state was added to
+	machine *StateMachine[Model, Input]
 }
 
 // String returns the string representation of the state.
@@ -37,7 +44,7 @@ func (s *State[Model, Input]) GetKey() StateKey {
 
 // Execute performs the state's action and returns the key of the next state.
 func (s *State[Model, Input]) Execute(model *Model, input Input) (key StateKey, err error) {
-	return s.Action(s, model, input)
+	return s.ExecuteContext(context.Background(), model, input)
 }
 
 // NewState creates a new state with the given key and action.
@@ -59,10 +66,30 @@ func NewState[Model any, Input any](
 // ============================================================================
 
 // StateMachine represents a state machine with a current state and a collection of states.
+// It is not safe for concurrent use: apart from Post and StopActivity, its methods must
+// be called from one goroutine at a time.
 type StateMachine[Model any, Input any] struct {
 	currentState *State[Model, Input]
 	states       map[StateKey]*State[Model, Input]
 	name         string
+	coverage     *Coverage
+	events       EventTable
+	completions  CompletionTable
+	errorStates  map[StateKey]StateKey
+	errorState   StateKey
+	retries      map[StateKey]RetryPolicy
+	retry        RetryPolicy
+	clock        Clock
+	err          error
+	middleware   []Middleware[Model, Input]
+	activities   map[StateKey]Activity[Input]
+	activity     *runningActivity
+	activityMu   sync.Mutex // guards activity and orders an activity's Post with its cancellation
+	initial      StateKey
+	final        map[StateKey]bool
+	stopped      bool
+	queue        inputQueue[Input]
+	queueLimit   int
 }
 
 // NewStateMachine creates a new state machine with the given model and name.
@@ -71,6 +98,8 @@ func NewStateMachine[Model any, Input any](model *Model, name string) *StateMach
 		currentState: nil,
 		states:       make(map[StateKey]*State[Model, Input]),
 		name:         name,
+		queueLimit:   DefaultQueueLimit,
+		clock:        systemClock{},
 	}
 }
 
@@ -101,6 +130,7 @@ func (sm *StateMachine[Model, Input]) AddState(state *State[Model, Input]) error
 	}
 	// ok, add it to the map
 	sm.states[state.Key] = state
+	state.machine = sm
 
 	// if there is no current state, set it as the initial state
 	if sm.currentState == nil {
@@ -119,24 +149,65 @@ func (sm *StateMachine[Model, Input]) SetInitialState(key StateKey) error {
 		return fmt.Errorf("state %v does not exist", key)
 	}
 
+	sm.StopActivity()
+	sm.initial = key
 	sm.currentState = state
+	sm.startActivity()
 
 	return nil
 }
 
+// SetCoverage attaches a coverage recorder that counts every transition
+// taken by Execute. An action that returns its own key stays put and is
+// only counted as a self-transition for the states named with
+// Coverage.SetSelfLoops; in event table mode the table says which states
+// loop. Passing nil stops recording.
+func (sm *StateMachine[Model, Input]) SetCoverage(c *Coverage) {
+	sm.coverage = c
+}
+
 // Execute performs the current state's action and transitions to the next state based on the returned key.
+// An action error moves the machine to the state's error target, if it has one; see SetErrorState.
+// Completion transitions out of the new state are followed; see SetCompletions.
+// Events the actions post are then processed in order before Execute returns; see Post.
+// If any of them fails, or the queue limit is reached, the remaining queued events are discarded.
+// Actions get a background context; see ExecuteContext.
+// Once the machine is in a final state or stopped, Execute fails with a *FinishedError; see SetFinalStates.
 func (sm *StateMachine[Model, Input]) Execute(model *Model, input Input) (key StateKey, err error) {
+	return sm.ExecuteContext(context.Background(), model, input)
+}
+
+// step processes a single input.
+func (sm *StateMachine[Model, Input]) step(ctx context.Context, model *Model, input Input) (key StateKey, err error) {
 	if sm.currentState == nil {
 		return "", fmt.Errorf("no current state set")
 	}
+	if err := sm.finished(); err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if sm.events != nil {
+		if _, err = sm.executeEvent(ctx, model, input); err != nil {
+			return "", err
+		}
+		return sm.complete(ctx, model)
+	}
 
-	key, err = sm.currentState.Execute(model, input)
+	key, err = sm.run(ctx, model, input)
 	if err != nil {
-		return
+		if _, err = sm.fail(err); err != nil {
+			return "", err
+		}
+		return sm.complete(ctx, model)
 	}
 
 	// same state, no change
 	if key == sm.currentState.GetKey() {
+		if sm.coverage != nil && sm.coverage.selfLoop(key) {
+			sm.coverage.Record(key, key)
+		}
 		return key, nil
 	}
 
@@ -146,8 +217,8 @@ func (sm *StateMachine[Model, Input]) Execute(model *Model, input Input) (key St
 		return "", fmt.Errorf("state %v does not exist", key)
 	}
 
-	// set next state
-	sm.currentState = newState
+	// set next state, then follow any completion transitions
+	sm.transition(newState)
 
-	return newState.GetKey(), nil
+	return sm.complete(ctx, model)
 }